@@ -3,63 +3,73 @@ package main
 import (
     "fmt"
     "github.com/hyperledger/fabric-contract-api-go/contractapi"
-    "strings"
-    "encoding/base64"
 )
 
-// getCallerRole extracts the role from client identity
+// getCallerRole extracts the role from client identity.
+// Only registrar-controlled data counts: Fabric CA attributes, NodeOUs, admins configured for the caller's MSP,
+// then the CN labels below <MSP ID>.<regnum>.<orbis domain>. Subject fields from the CSR (O, L, ...) are ignored.
 func getCallerRole(ctx contractapi.TransactionContextInterface) (string, error) {
-    // 1) Fabric-CA-Attribut "admin=true"
+    // 1) Fabric-CA-Attribut "admin=true" bzw. "role=admin|gens|human"
     adminAttr, found, err := ctx.GetClientIdentity().GetAttributeValue("admin")
     if err == nil && found && adminAttr == "true" {
         return "admin", nil
     }
-
-    rawID, err := ctx.GetClientIdentity().GetID()
-    if err != nil {
-        return "", fmt.Errorf("failed to get client identity: %v", err)
+    roleAttr, found, err := ctx.GetClientIdentity().GetAttributeValue("role")
+    if err == nil && found && isKnownRole(roleAttr) {
+        return roleAttr, nil
     }
 
-    // Base64-dekodieren
-    decoded, err := base64.StdEncoding.DecodeString(rawID)
+    id, err := getCallerIdentity(ctx)
     if err != nil {
-        return "", fmt.Errorf("failed to base64-decode client identity: %v", err)
+        return "", err
     }
-    clientID := string(decoded)
 
-    // Einfacher Admin-Match über CN
-    if strings.Contains(clientID, "CN=admin.alps.ea.jedo.cc") {
-        return "admin", nil
+    // 2) NodeOUs, z.B. OU=admin
+    for _, role := range []string{"admin", "gens", "human"} {
+        if id.hasOU(role) {
+            return role, nil
+        }
     }
 
-    // Beispiel: x509::CN=admin.alps.ea.jedo.cc,OU=...,O=alps,...
-    parts := strings.Split(clientID, "::")
-    if len(parts) < 2 {
-        return "", fmt.Errorf("unexpected clientID format: %s", clientID)
+    // 3) Für den Ager (MSP-ID des Callers) konfigurierte Admin-Identitäten
+    admins, err := getAgerAdmins(ctx, id.MSPID)
+    if err != nil {
+        return "", err
     }
-    subject := parts[1]
-
-    for _, p := range strings.Split(subject, ",") {
-        p = strings.TrimSpace(p)
-        if strings.HasPrefix(p, "CN=") {
-            cn := strings.TrimPrefix(p, "CN=")
-            cnParts := strings.Split(cn, ".")
-
-            if len(cnParts) > 0 && cnParts[0] == "admin" {
+    if admins != nil {
+        for _, adminID := range admins.AdminIDs {
+            if adminID == id.CN {
                 return "admin", nil
             }
-            if len(cnParts) == 4 {
-                return "gens", nil
-            }
-            if len(cnParts) > 4 {
-                return "human", nil
-            }
         }
     }
 
-    return "", fmt.Errorf("unknown role for identity: %s", clientID)
+    // 4) CN-Labels vor <msp>.<regnum>.<orbis-domain>, z.B. hans.worb.alps.ea.jedo.dev -> [hans worb]
+    orbis, err := getOrbisConfig(ctx)
+    if err != nil {
+        return "", err
+    }
+    prefix := id.unitPrefix(orbis.Domain)
+    switch len(prefix) {
+    case 1:
+        if prefix[0] != "admin" {
+            return "gens", nil
+        }
+        // admin.<ager>... gilt nur, solange für den Ager keine Admins konfiguriert sind
+        if admins == nil {
+            return "admin", nil
+        }
+    case 2:
+        return "human", nil
+    }
+
+    return "", fmt.Errorf("unknown role for identity: %s", id.CN)
 }
 
+// isKnownRole checks if role is one of the chaincode roles
+func isKnownRole(role string) bool {
+    return role == "admin" || role == "gens" || role == "human"
+}
 
 // isAdmin checks if caller is admin
 func isAdmin(ctx contractapi.TransactionContextInterface) bool {
@@ -70,6 +80,39 @@ func isAdmin(ctx contractapi.TransactionContextInterface) bool {
     return role == "admin"
 }
 
+// isAgerAdmin checks if caller is an admin of the given Ager (issued by its MSP) or an Orbis admin
+func isAgerAdmin(ctx contractapi.TransactionContextInterface, agerID string) bool {
+    if !isAdmin(ctx) {
+        return false
+    }
+    mspID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return false
+    }
+    return mspID == agerID || isOrbisAdmin(ctx)
+}
+
+// isOrbisAdmin checks if caller is an admin issued by one of the Orbis admin MSPs
+func isOrbisAdmin(ctx contractapi.TransactionContextInterface) bool {
+    if !isAdmin(ctx) {
+        return false
+    }
+    mspID, err := ctx.GetClientIdentity().GetMSPID()
+    if err != nil {
+        return false
+    }
+    orbis, err := getOrbisConfig(ctx)
+    if err != nil {
+        return false
+    }
+    for _, adminMSP := range orbis.AdminMSPs {
+        if adminMSP == mspID {
+            return true
+        }
+    }
+    return false
+}
+
 // isGens checks if caller is gens
 func isGens(ctx contractapi.TransactionContextInterface) bool {
     role, err := getCallerRole(ctx)
@@ -87,3 +130,25 @@ func isHuman(ctx contractapi.TransactionContextInterface) bool {
     }
     return role == "human"
 }
+
+// getCallerCN returns the common name of the caller certificate (e.g. hans.worb.alps.ea.jedo.cc)
+func getCallerCN(ctx contractapi.TransactionContextInterface) (string, error) {
+    id, err := getCallerIdentity(ctx)
+    if err != nil {
+        return "", err
+    }
+    return id.CN, nil
+}
+
+// isOfficeHolder checks if caller is the elected official of the given unit
+func isOfficeHolder(ctx contractapi.TransactionContextInterface, level string, unitID string) bool {
+    caller, err := getCallerIdentity(ctx)
+    if err != nil {
+        return false
+    }
+    holder, err := getOfficeHolder(ctx, level, unitID)
+    if err != nil || holder == nil {
+        return false
+    }
+    return caller.isOwner(holder.HolderID, levelHuman)
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// AgerAdmins lists the admin identities (certificate CNs) of an Ager.
+// Once an Ager has this document, an admin.<ager>... CN alone no longer makes a caller admin.
+type AgerAdmins struct {
+	DocType   string   `json:"docType"`
+	AgerID    string   `json:"agerId"`
+	AdminIDs  []string `json:"adminIds"`
+	UpdatedBy string   `json:"updatedBy"`
+	UpdatedAt string   `json:"updatedAt"`
+}
+
+// agerAdminsKey returns the state key of the admin configuration of an Ager
+func agerAdminsKey(ctx contractapi.TransactionContextInterface, agerID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("config", []string{"agerAdmins", agerID})
+}
+
+// AddAgerAdmin adds an admin identity to an Ager (admin of that Ager or Orbis admin)
+func (s *SmartContract) AddAgerAdmin(ctx contractapi.TransactionContextInterface, agerID string, adminID string) error {
+	// Admin check, an admin of another Ager's MSP must not configure this one
+	if !isAgerAdmin(ctx, agerID) {
+		return fmt.Errorf("only an admin of ager %s can configure its admin identities", agerID)
+	}
+
+	if err := validateUnitID(levelAger, agerID); err != nil {
+		return err
+	}
+	if err := validateOwnerID(adminID); err != nil {
+		return err
+	}
+	if labels := strings.Split(adminID, "."); len(labels) < 4 || labels[1] != agerID {
+		return fmt.Errorf("admin ID %s does not belong to ager %s", adminID, agerID)
+	}
+
+	admins, err := getAgerAdmins(ctx, agerID)
+	if err != nil {
+		return err
+	}
+	if admins == nil {
+		admins = &AgerAdmins{AgerID: agerID}
+	}
+	for _, existing := range admins.AdminIDs {
+		if existing == adminID {
+			return fmt.Errorf("%s is already an admin of ager %s", adminID, agerID)
+		}
+	}
+	admins.AdminIDs = append(admins.AdminIDs, adminID)
+
+	return putAgerAdmins(ctx, admins, "AgerAdminAdded", adminID)
+}
+
+// RemoveAgerAdmin removes an admin identity from an Ager (admin of that Ager or Orbis admin)
+func (s *SmartContract) RemoveAgerAdmin(ctx contractapi.TransactionContextInterface, agerID string, adminID string) error {
+	// Admin check, an admin of another Ager's MSP must not configure this one
+	if !isAgerAdmin(ctx, agerID) {
+		return fmt.Errorf("only an admin of ager %s can configure its admin identities", agerID)
+	}
+
+	admins, err := getAgerAdmins(ctx, agerID)
+	if err != nil {
+		return err
+	}
+	if admins == nil {
+		return fmt.Errorf("ager %s has no configured admins", agerID)
+	}
+
+	remaining := make([]string, 0, len(admins.AdminIDs))
+	for _, existing := range admins.AdminIDs {
+		if existing != adminID {
+			remaining = append(remaining, existing)
+		}
+	}
+	if len(remaining) == len(admins.AdminIDs) {
+		return fmt.Errorf("%s is not an admin of ager %s", adminID, agerID)
+	}
+	admins.AdminIDs = remaining
+
+	return putAgerAdmins(ctx, admins, "AgerAdminRemoved", adminID)
+}
+
+// GetAgerAdmins returns the configured admin identities of an Ager (public)
+func (s *SmartContract) GetAgerAdmins(ctx contractapi.TransactionContextInterface, agerID string) (*AgerAdmins, error) {
+	admins, err := getAgerAdmins(ctx, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if admins == nil {
+		return nil, fmt.Errorf("ager %s has no configured admins", agerID)
+	}
+	return admins, nil
+}
+
+// getAgerAdmins reads the admin configuration of an Ager, nil if there is none
+func getAgerAdmins(ctx contractapi.TransactionContextInterface, agerID string) (*AgerAdmins, error) {
+	key, err := agerAdminsKey(ctx, agerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	adminsJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read admin configuration: %v", err)
+	}
+	if adminsJSON == nil {
+		return nil, nil
+	}
+
+	var admins AgerAdmins
+	if err := json.Unmarshal(adminsJSON, &admins); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal admin configuration: %v", err)
+	}
+	return &admins, nil
+}
+
+// putAgerAdmins stores the admin configuration of an Ager and emits eventName
+func putAgerAdmins(ctx contractapi.TransactionContextInterface, admins *AgerAdmins, eventName string, adminID string) error {
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	admins.DocType = "agerAdmins"
+	admins.UpdatedBy = callerID
+	admins.UpdatedAt = now.Format(time.RFC3339)
+
+	adminsJSON, err := json.Marshal(admins)
+	if err != nil {
+		return err
+	}
+
+	key, err := agerAdminsKey(ctx, admins.AgerID)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, adminsJSON); err != nil {
+		return fmt.Errorf("failed to save admin configuration: %v", err)
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"agerId":    admins.AgerID,
+		"adminId":   adminID,
+		"timestamp": admins.UpdatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent(eventName, eventJSON)
+
+	return nil
+}
+
+// OrbisConfig holds the Orbis-wide identity settings.
+// Domain is the Orbis domain of all CNs (e.g. jedo.dev), AdminMSPs lists the MSPs whose admins act for the whole Orbis.
+type OrbisConfig struct {
+	DocType   string   `json:"docType"`
+	Domain    string   `json:"domain"`
+	AdminMSPs []string `json:"adminMsps"`
+	UpdatedBy string   `json:"updatedBy,omitempty"`
+	UpdatedAt string   `json:"updatedAt,omitempty"`
+}
+
+// orbisConfigKey returns the state key of the Orbis configuration
+func orbisConfigKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("config", []string{"orbis"})
+}
+
+// SetOrbisConfig sets the Orbis domain and the Orbis admin MSPs (Orbis admin only;
+// the first configuration may be made by any admin while the bootstrap mode is active)
+func (s *SmartContract) SetOrbisConfig(ctx contractapi.TransactionContextInterface, domain string, adminMSPsJSON string) error {
+	config, err := getOrbisConfig(ctx)
+	if err != nil {
+		return err
+	}
+	if len(config.AdminMSPs) > 0 {
+		if !isOrbisAdmin(ctx) {
+			return fmt.Errorf("only an Orbis admin can change the Orbis configuration")
+		}
+	} else {
+		if !isAdmin(ctx) {
+			return fmt.Errorf("only admin can configure the Orbis")
+		}
+		if err := s.requireBootstrapMode(ctx, "the first Orbis configuration"); err != nil {
+			return err
+		}
+	}
+
+	if err := validateUnitID("orbis domain", domain); err != nil {
+		return err
+	}
+	if !strings.Contains(domain, ".") {
+		return fmt.Errorf("orbis domain %s must contain a dot", domain)
+	}
+
+	var adminMSPs []string
+	if err := json.Unmarshal([]byte(adminMSPsJSON), &adminMSPs); err != nil {
+		return fmt.Errorf("failed to parse admin MSPs: %v", err)
+	}
+	if len(adminMSPs) == 0 {
+		return fmt.Errorf("at least one Orbis admin MSP is required")
+	}
+	for _, mspID := range adminMSPs {
+		if err := validateUnitID("MSP", mspID); err != nil {
+			return err
+		}
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	config.Domain = domain
+	config.AdminMSPs = adminMSPs
+	config.UpdatedBy = callerID
+	config.UpdatedAt = now.Format(time.RFC3339)
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	key, err := orbisConfigKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, configJSON); err != nil {
+		return fmt.Errorf("failed to save Orbis configuration: %v", err)
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"domain":    domain,
+		"adminMsps": adminMSPs,
+		"timestamp": config.UpdatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("OrbisConfigUpdated", eventJSON)
+
+	return nil
+}
+
+// GetOrbisConfig returns the Orbis configuration (public)
+func (s *SmartContract) GetOrbisConfig(ctx contractapi.TransactionContextInterface) (*OrbisConfig, error) {
+	return getOrbisConfig(ctx)
+}
+
+// getOrbisConfig reads the Orbis configuration, empty if it was never set
+func getOrbisConfig(ctx contractapi.TransactionContextInterface) (*OrbisConfig, error) {
+	key, err := orbisConfigKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	configJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Orbis configuration: %v", err)
+	}
+	if configJSON == nil {
+		return &OrbisConfig{DocType: "orbisConfig", AdminMSPs: []string{}}, nil
+	}
+
+	var config OrbisConfig
+	if err := json.Unmarshal(configJSON, &config); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Orbis configuration: %v", err)
+	}
+	return &config, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// amountDecimals is the number of decimal places of a JEDO amount on the contract API
+const amountDecimals = 2
+
+// amountScale is the number of minor units per JEDO (1 JEDO = 100 minor units)
+const amountScale int64 = 100
+
+// parseAmount converts a non-negative decimal string (e.g. "12.5") into minor units
+func parseAmount(value string) (int64, error) {
+	minor, err := parseSignedAmount(value)
+	if err != nil {
+		return 0, err
+	}
+	if minor < 0 {
+		return 0, fmt.Errorf("amount %q must not be negative", value)
+	}
+	return minor, nil
+}
+
+// parseSignedAmount converts a decimal string (e.g. "-12.50") into minor units without rounding
+func parseSignedAmount(value string) (int64, error) {
+	return parseDecimal(value, amountDecimals)
+}
+
+// parseDecimal converts a decimal string into an integer scaled by 10^decimals without rounding
+func parseDecimal(value string, decimals int) (int64, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return 0, fmt.Errorf("amount cannot be empty")
+	}
+
+	negative := false
+	if s[0] == '-' || s[0] == '+' {
+		negative = s[0] == '-'
+		s = s[1:]
+	}
+
+	intPart, fracPart := s, ""
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		intPart, fracPart = s[:dot], s[dot+1:]
+	}
+	if intPart == "" && fracPart == "" {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	// Trailing zeros beyond the supported precision do not change the value
+	fracPart = strings.TrimRight(fracPart, "0")
+	if len(fracPart) > decimals {
+		return 0, fmt.Errorf("amount %q has more than %d decimal places", value, decimals)
+	}
+	fracPart += strings.Repeat("0", decimals-len(fracPart))
+
+	for _, part := range []string{intPart, fracPart} {
+		for _, c := range part {
+			if c < '0' || c > '9' {
+				return 0, fmt.Errorf("invalid amount %q", value)
+			}
+		}
+	}
+
+	scale := int64(1)
+	for i := 0; i < decimals; i++ {
+		scale *= 10
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || units > math.MaxInt64/scale {
+		return 0, fmt.Errorf("amount %q is out of range", value)
+	}
+	frac := int64(0)
+	if fracPart != "" {
+		frac, err = strconv.ParseInt(fracPart, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid amount %q", value)
+		}
+	}
+
+	minor := units*scale + frac
+	if minor < 0 {
+		return 0, fmt.Errorf("amount %q is out of range", value)
+	}
+	if negative {
+		minor = -minor
+	}
+	return minor, nil
+}
+
+// btcDecimals is the number of decimal places of a BTC amount (1 BTC = 100,000,000 sats)
+const btcDecimals = 8
+
+// parseBTCAmount converts a positive BTC decimal string (e.g. "0.0125") into sats
+func parseBTCAmount(value string) (int64, error) {
+	sats, err := parseDecimal(value, btcDecimals)
+	if err != nil {
+		return 0, err
+	}
+	if sats <= 0 {
+		return 0, fmt.Errorf("BTC amount %q must be positive", value)
+	}
+	return sats, nil
+}
+
+// formatAmount renders minor units as a decimal string with a fixed number of decimals (e.g. "12.50")
+func formatAmount(minor int64) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+	abs := uint64(minor)
+	if minor < 0 {
+		abs = uint64(-(minor + 1)) + 1
+	}
+	scale := uint64(amountScale)
+	return fmt.Sprintf("%s%d.%0*d", sign, abs/scale, amountDecimals, abs%scale)
+}
+
+// addAmounts adds two minor-unit amounts and fails instead of overflowing
+func addAmounts(a int64, b int64) (int64, error) {
+	if (b > 0 && a > math.MaxInt64-b) || (b < 0 && a < math.MinInt64-b) {
+		return 0, fmt.Errorf("amount overflow")
+	}
+	return a + b, nil
+}
+
+// mulDiv computes a*b/c in minor units, rounding towards zero and failing on overflow
+func mulDiv(a int64, b int64, c int64) (int64, error) {
+	if c == 0 {
+		return 0, fmt.Errorf("division by zero")
+	}
+	result := new(big.Int).Mul(big.NewInt(a), big.NewInt(b))
+	result.Quo(result, big.NewInt(c))
+	if !result.IsInt64() {
+		return 0, fmt.Errorf("amount overflow")
+	}
+	return result.Int64(), nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestParseAmount(t *testing.T) {
+	tests := []struct {
+		value   string
+		minor   int64
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"12", 1200, false},
+		{"12.5", 1250, false},
+		{"12.50", 1250, false},
+		{"12.500", 1250, false},
+		{"0.01", 1, false},
+		{".5", 50, false},
+		{"7.", 700, false},
+		{" 3.10 ", 310, false},
+		{"+1", 100, false},
+		{"92233720368547758.07", math.MaxInt64, false},
+		{"0.001", 0, true},
+		{"0.30000000000000004", 0, true},
+		{"-1", 0, true},
+		{"", 0, true},
+		{".", 0, true},
+		{"1e3", 0, true},
+		{"1,50", 0, true},
+		{"12.5.0", 0, true},
+		{"92233720368547758.08", 0, true},
+	}
+
+	for _, tt := range tests {
+		minor, err := parseAmount(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseAmount(%q) = %d, want error", tt.value, minor)
+			}
+			continue
+		}
+		if err != nil || minor != tt.minor {
+			t.Errorf("parseAmount(%q) = %d, %v, want %d", tt.value, minor, err, tt.minor)
+		}
+	}
+}
+
+func TestFormatAmount(t *testing.T) {
+	tests := []struct {
+		minor int64
+		want  string
+	}{
+		{0, "0.00"},
+		{1, "0.01"},
+		{1250, "12.50"},
+		{-5, "-0.05"},
+		{-1250, "-12.50"},
+		{math.MaxInt64, "92233720368547758.07"},
+		{math.MinInt64, "-92233720368547758.08"},
+	}
+
+	for _, tt := range tests {
+		if got := formatAmount(tt.minor); got != tt.want {
+			t.Errorf("formatAmount(%d) = %q, want %q", tt.minor, got, tt.want)
+		}
+		if tt.minor < 0 && tt.minor != math.MinInt64 {
+			if back, err := parseSignedAmount(tt.want); err != nil || back != tt.minor {
+				t.Errorf("parseSignedAmount(%q) = %d, %v, want %d", tt.want, back, err, tt.minor)
+			}
+		}
+	}
+}
+
+func TestAddAmounts(t *testing.T) {
+	tests := []struct {
+		a, b    int64
+		want    int64
+		wantErr bool
+	}{
+		{100, 250, 350, false},
+		{-100, 50, -50, false},
+		{math.MaxInt64, 0, math.MaxInt64, false},
+		{math.MaxInt64, 1, 0, true},
+		{math.MinInt64, -1, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := addAmounts(tt.a, tt.b)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("addAmounts(%d, %d) = %d, %v, want %d (error %v)", tt.a, tt.b, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestMulDiv(t *testing.T) {
+	tests := []struct {
+		a, b, c int64
+		want    int64
+		wantErr bool
+	}{
+		{1000, 1, 3, 333, false},
+		{1000, 2, 3, 666, false},
+		{-1000, 2, 3, -666, false},
+		{math.MaxInt64, 3, 3, math.MaxInt64, false},
+		{math.MaxInt64, 3, 2, 0, true},
+		{100, 1, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := mulDiv(tt.a, tt.b, tt.c)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("mulDiv(%d, %d, %d) = %d, %v, want %d (error %v)", tt.a, tt.b, tt.c, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestRoundLegacyAmount(t *testing.T) {
+	tests := []struct {
+		literal string
+		minor   int64
+		delta   string
+	}{
+		{"12.5", 1250, "0.00"},
+		{"0.30000000000000004", 30, "-0.00000000000000004"},
+		{"0.125", 13, "0.005"},
+		{"-0.125", -13, "-0.005"},
+		{"0.124999", 12, "-0.004999"},
+		{"1e2", 10000, "0.00"},
+	}
+
+	for _, tt := range tests {
+		minor, delta, err := roundLegacyAmount(json.Number(tt.literal))
+		if err != nil {
+			t.Errorf("roundLegacyAmount(%s): %v", tt.literal, err)
+			continue
+		}
+		if minor != tt.minor || exactDecimal(delta) != tt.delta {
+			t.Errorf("roundLegacyAmount(%s) = %d, %s, want %d, %s", tt.literal, minor, exactDecimal(delta), tt.minor, tt.delta)
+		}
+	}
+
+	if _, _, err := roundLegacyAmount(json.Number("1e30")); err == nil {
+		t.Errorf("roundLegacyAmount(1e30) succeeded, want out of range")
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxBatchRecipients limits a TransferBatch so it stays within the peer's execution timeout
+const maxBatchRecipients = 500
+
+// BatchRecipient is one entry of the recipients JSON of TransferBatch
+type BatchRecipient struct {
+	WalletID    string `json:"walletId"`
+	Amount      string `json:"amount"`
+	Description string `json:"description"`
+}
+
+// TransferBatch transfers from one wallet to many in a single all-or-nothing transaction, e.g. a payroll
+// (only the owner of the source wallet, human or gens). recipientsJSON is a list of BatchRecipient,
+// each wallet at most once; it may come as transient field "recipients" like a transfer description.
+func (s *SmartContract) TransferBatch(ctx contractapi.TransactionContextInterface, fromWalletID string, recipientsJSON string) error {
+	callerRole, err := getCallerRole(ctx)
+	if err != nil {
+		return err
+	}
+	if callerRole != "human" && callerRole != "gens" {
+		return fmt.Errorf("only humans and gens can transfer tokens")
+	}
+
+	// The recipients carry descriptions and may come as transient data so they never appear in the proposal
+	recipientsJSON, err = getSensitiveArg(ctx, "recipients", recipientsJSON)
+	if err != nil {
+		return err
+	}
+
+	var recipients []BatchRecipient
+	if err := json.Unmarshal([]byte(recipientsJSON), &recipients); err != nil {
+		return fmt.Errorf("failed to parse recipients: %v", err)
+	}
+	if len(recipients) == 0 {
+		return fmt.Errorf("batch needs at least one recipient")
+	}
+	if len(recipients) > maxBatchRecipients {
+		return fmt.Errorf("batch must not exceed %d recipients", maxBatchRecipients)
+	}
+
+	amounts := make([]int64, len(recipients))
+	fingerprint := []string{fromWalletID}
+	seen := make(map[string]bool)
+	var total int64
+	for i, recipient := range recipients {
+		if recipient.WalletID == fromWalletID {
+			return fmt.Errorf("recipient %d: cannot transfer to the source wallet", i+1)
+		}
+		if seen[recipient.WalletID] {
+			return fmt.Errorf("recipient %d: wallet %s appears more than once", i+1, recipient.WalletID)
+		}
+		seen[recipient.WalletID] = true
+
+		if amounts[i], err = parseAmount(recipient.Amount); err != nil {
+			return fmt.Errorf("recipient %d: %v", i+1, err)
+		}
+		if amounts[i] <= 0 {
+			return fmt.Errorf("recipient %d: transfer amount must be positive", i+1)
+		}
+		if total, err = addAmounts(total, amounts[i]); err != nil {
+			return err
+		}
+		fingerprint = append(fingerprint, recipient.WalletID, formatAmount(amounts[i]))
+	}
+
+	// A retry with the same client request ID is not applied twice
+	duplicate, err := claimClientRequest(ctx, "TransferBatch", fingerprint...)
+	if err != nil || duplicate {
+		return err
+	}
+
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	fromWallet, err := s.GetWallet(ctx, fromWalletID)
+	if err != nil {
+		return fmt.Errorf("source wallet error: %v", err)
+	}
+	if !caller.isOwner(fromWallet.OwnerID, fromWallet.OwnerType) {
+		return fmt.Errorf("you can only transfer from your own wallet")
+	}
+	if fromWallet.Status != "active" {
+		return fmt.Errorf("source wallet %s is not active (status: %s)", fromWalletID, fromWallet.Status)
+	}
+	if fromWallet.Balance < total {
+		return fmt.Errorf("insufficient balance: wallet %s has %s but batch requires %s", fromWalletID, formatAmount(fromWallet.Balance), formatAmount(total))
+	}
+
+	// Check every recipient before anything is written
+	toWallets := make([]*Wallet, len(recipients))
+	pendingByOwner := make(map[string]int64) // Credits of this batch to other wallets of the same owner
+	for i, recipient := range recipients {
+		toWallet, err := s.GetWallet(ctx, recipient.WalletID)
+		if err != nil {
+			return fmt.Errorf("recipient %d: destination wallet error: %v", i+1, err)
+		}
+		if err := checkTransferDestination(ctx, fromWallet, toWallet); err != nil {
+			return fmt.Errorf("recipient %d: %v", i+1, err)
+		}
+
+		// Enforce holding cap of the receiving owner (moving funds between own wallets changes nothing)
+		if fromWallet.OwnerID != toWallet.OwnerID {
+			credit, err := addAmounts(pendingByOwner[toWallet.OwnerID], amounts[i])
+			if err != nil {
+				return err
+			}
+			if err := s.checkHoldingCap(ctx, toWallet, credit); err != nil {
+				return fmt.Errorf("recipient %d: %v", i+1, err)
+			}
+			pendingByOwner[toWallet.OwnerID] = credit
+		}
+		toWallets[i] = toWallet
+	}
+
+	// Perform transfers at the transaction time, which is the same on every endorsing peer
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	now := txTime.Format(time.RFC3339)
+	txID := ctx.GetStub().GetTxID()
+
+	type batchEntry struct {
+		WalletID string `json:"walletId"`
+		Amount   string `json:"amount"`
+	}
+	entries := make([]batchEntry, len(recipients))
+
+	for i, toWallet := range toWallets {
+		fromWallet.Balance -= amounts[i]
+		toWallet.Balance += amounts[i]
+		toWallet.UpdatedAt = now
+
+		if err := putWalletState(ctx, toWallet); err != nil {
+			return err
+		}
+
+		// Record debit transaction, one per recipient with the running balance
+		debitTx := Transaction{
+			TxID:         txID,
+			WalletID:     fromWalletID,
+			Type:         "transfer_out",
+			Amount:       -amounts[i],
+			Balance:      fromWallet.Balance,
+			Counterparty: toWallet.WalletID,
+			Description:  recipients[i].Description,
+			Timestamp:    now,
+			Index:        i + 1,
+		}
+		if err := putTransaction(ctx, fromWallet, &debitTx); err != nil {
+			return err
+		}
+
+		// Record credit transaction
+		creditTx := Transaction{
+			TxID:         txID,
+			WalletID:     toWallet.WalletID,
+			Type:         "transfer_in",
+			Amount:       amounts[i],
+			Balance:      toWallet.Balance,
+			Counterparty: fromWalletID,
+			Description:  recipients[i].Description,
+			Timestamp:    now,
+		}
+		if err := putTransaction(ctx, toWallet, &creditTx); err != nil {
+			return err
+		}
+
+		entries[i] = batchEntry{WalletID: toWallet.WalletID, Amount: formatAmount(amounts[i])}
+	}
+
+	fromWallet.UpdatedAt = now
+	if err := putWalletState(ctx, fromWallet); err != nil {
+		return err
+	}
+
+	// Count the batch once for the voting activity of each owner involved, credits to own wallets do not count
+	var activityWallets []*Wallet
+	for _, toWallet := range toWallets {
+		if toWallet.OwnerID != fromWallet.OwnerID {
+			activityWallets = append(activityWallets, toWallet)
+		}
+	}
+	if len(activityWallets) > 0 {
+		if err := recordTransferActivity(ctx, append(activityWallets, fromWallet)...); err != nil {
+			return err
+		}
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"txId":         txID,
+		"fromWalletId": fromWalletID,
+		"recipients":   entries,
+		"count":        len(entries),
+		"total":        formatAmount(total),
+		"fromBalance":  formatAmount(fromWallet.Balance),
+		"timestamp":    now,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("BatchTransferCompleted", eventJSON)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// newBatchLedger returns a ledger with a payer of 10 JEDO, two recipients of one owner and a gens under a 10 JEDO cap
+func newBatchLedger(t *testing.T) *testLedger {
+	l := newTestLedger(t)
+	l.putWallet(&Wallet{WalletID: "hans-1", OwnerID: "hans.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Balance: 1000})
+	l.putWallet(&Wallet{WalletID: "vreni-1", OwnerID: "vreni.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Balance: 800})
+	l.putWallet(&Wallet{WalletID: "vreni-2", OwnerID: "vreni.worb.alps.ea.jedo.dev", OwnerType: levelHuman})
+	l.putWallet(&Wallet{WalletID: "worb-1", OwnerID: "worb.alps.ea.jedo.dev", OwnerType: levelGens})
+	l.putWallet(&Wallet{WalletID: "frozen-1", OwnerID: "ueli.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Status: "frozen"})
+	l.setHoldingCap("10", "10")
+	return l
+}
+
+// transactionCount returns the number of transaction records on the ledger
+func (l *testLedger) transactionCount() int {
+	l.t.Helper()
+	count := 0
+	l.must(l.admin(func(ctx contractapi.TransactionContextInterface) error {
+		resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("transaction", []string{})
+		if err != nil {
+			return err
+		}
+		defer resultsIterator.Close()
+		for resultsIterator.HasNext() {
+			if _, err := resultsIterator.Next(); err != nil {
+				return err
+			}
+			count++
+		}
+		return nil
+	}))
+	return count
+}
+
+func TestTransferBatch(t *testing.T) {
+	l := newBatchLedger(t)
+	hans := newTestIdentity("hans.worb.alps.ea.jedo.dev", "alps", levelHuman)
+
+	l.must(l.invoke(hans, func(ctx contractapi.TransactionContextInterface) error {
+		return l.s.TransferBatch(ctx, "hans-1", `[{"walletId":"vreni-2","amount":"2"},{"walletId":"worb-1","amount":"3.5"}]`)
+	}))
+
+	want := map[string]int64{"hans-1": 450, "vreni-1": 800, "vreni-2": 200, "worb-1": 350}
+	for walletID, balance := range want {
+		if got := l.balance(walletID); got != balance {
+			t.Errorf("balance of %s = %s, want %s", walletID, formatAmount(got), formatAmount(balance))
+		}
+	}
+	if count := l.transactionCount(); count != 4 {
+		t.Errorf("%d transaction records, want a debit and a credit per recipient", count)
+	}
+}
+
+func TestTransferBatchAllOrNothing(t *testing.T) {
+	hans := newTestIdentity("hans.worb.alps.ea.jedo.dev", "alps", levelHuman)
+	vreni := newTestIdentity("vreni.worb.alps.ea.jedo.dev", "alps", levelHuman)
+
+	tests := []struct {
+		name       string
+		caller     *testIdentity
+		recipients string
+	}{
+		{"unknown recipient", hans, `[{"walletId":"worb-1","amount":"1"},{"walletId":"nobody-1","amount":"1"}]`},
+		{"inactive recipient", hans, `[{"walletId":"worb-1","amount":"1"},{"walletId":"frozen-1","amount":"1"}]`},
+		{"recipient listed twice", hans, `[{"walletId":"worb-1","amount":"1"},{"walletId":"worb-1","amount":"1"}]`},
+		{"recipient is the source", hans, `[{"walletId":"worb-1","amount":"1"},{"walletId":"hans-1","amount":"1"}]`},
+		{"invalid amount", hans, `[{"walletId":"worb-1","amount":"1"},{"walletId":"vreni-2","amount":"0.001"}]`},
+		{"zero amount", hans, `[{"walletId":"worb-1","amount":"1"},{"walletId":"vreni-2","amount":"0"}]`},
+		{"total above the balance", hans, `[{"walletId":"worb-1","amount":"6"},{"walletId":"vreni-2","amount":"4.01"}]`},
+		{"recipient above the holding cap", hans, `[{"walletId":"worb-1","amount":"1"},{"walletId":"vreni-1","amount":"2.01"}]`},
+		{"credits to one owner add up above the cap", hans, `[{"walletId":"vreni-2","amount":"1.5"},{"walletId":"vreni-1","amount":"1"}]`},
+		{"source wallet of another owner", vreni, `[{"walletId":"worb-1","amount":"1"}]`},
+		{"no recipients", hans, `[]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newBatchLedger(t)
+			before := l.transactionCount()
+
+			err := l.invoke(tt.caller, func(ctx contractapi.TransactionContextInterface) error {
+				return l.s.TransferBatch(ctx, "hans-1", tt.recipients)
+			})
+			if err == nil {
+				t.Fatal("TransferBatch succeeded, want error")
+			}
+
+			// Every check runs before the first write, so a rejected batch leaves nothing behind
+			want := map[string]int64{"hans-1": 1000, "vreni-1": 800, "vreni-2": 0, "worb-1": 0}
+			for walletID, balance := range want {
+				if got := l.balance(walletID); got != balance {
+					t.Errorf("balance of %s = %s after %v, want %s", walletID, formatAmount(got), err, formatAmount(balance))
+				}
+			}
+			if count := l.transactionCount(); count != before {
+				t.Errorf("%d transaction records after %v, want %d", count, err, before)
+			}
+		})
+	}
+}
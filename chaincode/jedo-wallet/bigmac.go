@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// defaultJedoPerBigMac is the worldwide peg of the JEDO (10 JEDO = 1 BigMac) in minor units
+const defaultJedoPerBigMac = 10 * amountScale
+
+// BigMacIndex is the price index an Ager sets by ordinance, valid from a given point in time
+type BigMacIndex struct {
+	DocType       string `json:"docType"`
+	AgerID        string `json:"agerId"`
+	JedoPerBigMac int64  `json:"jedoPerBigMac"` // JEDO per BigMac in minor units
+	FiatCurrency  string `json:"fiatCurrency"`  // ISO 4217 code of the local currency (e.g. CHF)
+	FiatPerBigMac int64  `json:"fiatPerBigMac"` // Local BigMac price in fiat minor units
+	SatsPerBigMac int64  `json:"satsPerBigMac"` // BigMac price in sats for on-ramp minting (0 = on-ramp disabled)
+	ValidFrom     string `json:"validFrom"`     // RFC3339 timestamp from which the index applies
+	SetBy         string `json:"setBy"`         // Identity that set the index
+	TxID          string `json:"txId"`
+	CreatedAt     string `json:"createdAt"`
+}
+
+// PriceConversion is the result of converting an amount with an Ager's BigMac index
+type PriceConversion struct {
+	AgerID         string `json:"agerId"`
+	Jedo           string `json:"jedo"`
+	BigMac         string `json:"bigMac"`
+	Fiat           string `json:"fiat"`
+	FiatCurrency   string `json:"fiatCurrency"`
+	IndexValidFrom string `json:"indexValidFrom"`
+}
+
+// SetBigMacIndex records a new BigMac index for an Ager (admin of that Ager only).
+// validFrom is an RFC3339 timestamp, empty means immediately; it must not lie in the past or before the latest index,
+// so an ordinance never changes prices that were already in force. satsPerBigMac is optional and enables BTC on-ramp minting.
+func (s *SmartContract) SetBigMacIndex(
+	ctx contractapi.TransactionContextInterface,
+	agerID string,
+	jedoPerBigMac string,
+	fiatCurrency string,
+	fiatPerBigMac string,
+	validFrom string,
+	satsPerBigMac string,
+) error {
+	// Admin check, an admin of another Ager's MSP must not set this Ager's prices
+	if !isAgerAdmin(ctx, agerID) {
+		return fmt.Errorf("only an admin of ager %s can set its BigMac index", agerID)
+	}
+
+	if err := validateUnitID("ager", agerID); err != nil {
+		return err
+	}
+
+	jedoMinor := defaultJedoPerBigMac
+	if strings.TrimSpace(jedoPerBigMac) != "" {
+		parsed, err := parseAmount(jedoPerBigMac)
+		if err != nil {
+			return fmt.Errorf("invalid JEDO per BigMac: %v", err)
+		}
+		jedoMinor = parsed
+	}
+	if jedoMinor <= 0 {
+		return fmt.Errorf("JEDO per BigMac must be positive")
+	}
+
+	fiatCurrency = strings.ToUpper(strings.TrimSpace(fiatCurrency))
+	if len(fiatCurrency) != 3 {
+		return fmt.Errorf("fiat currency must be a 3-letter ISO 4217 code")
+	}
+
+	fiatMinor, err := parseAmount(fiatPerBigMac)
+	if err != nil {
+		return fmt.Errorf("invalid fiat price: %v", err)
+	}
+	if fiatMinor <= 0 {
+		return fmt.Errorf("fiat price must be positive")
+	}
+
+	var satsMinor int64
+	if strings.TrimSpace(satsPerBigMac) != "" {
+		satsMinor, err = strconv.ParseInt(strings.TrimSpace(satsPerBigMac), 10, 64)
+		if err != nil || satsMinor < 0 {
+			return fmt.Errorf("sats per BigMac must be a non-negative integer")
+		}
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	validFromTime := txTime
+	if strings.TrimSpace(validFrom) != "" {
+		validFromTime, err = time.Parse(time.RFC3339, validFrom)
+		if err != nil {
+			return fmt.Errorf("invalid validFrom timestamp: %v", err)
+		}
+		if validFromTime.Before(txTime) {
+			return fmt.Errorf("validFrom %s lies before the transaction time", validFrom)
+		}
+	}
+
+	latest, err := getLatestBigMacIndex(ctx, agerID)
+	if err != nil {
+		return err
+	}
+	if latest != nil && validFromTime.UTC().Format(time.RFC3339) <= latest.ValidFrom {
+		return fmt.Errorf("validFrom must be after %s, the validFrom of the latest BigMac index of ager %s", latest.ValidFrom, agerID)
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	index := BigMacIndex{
+		DocType:       "bigMacIndex",
+		AgerID:        agerID,
+		JedoPerBigMac: jedoMinor,
+		FiatCurrency:  fiatCurrency,
+		FiatPerBigMac: fiatMinor,
+		SatsPerBigMac: satsMinor,
+		ValidFrom:     validFromTime.UTC().Format(time.RFC3339),
+		SetBy:         callerID,
+		TxID:          ctx.GetStub().GetTxID(),
+		CreatedAt:     txTime.Format(time.RFC3339),
+	}
+
+	// History key sorts chronologically by validFrom within an Ager
+	indexKey, err := ctx.GetStub().CreateCompositeKey("bigMacIndex", []string{agerID, index.ValidFrom, index.TxID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(indexKey, indexJSON); err != nil {
+		return fmt.Errorf("failed to save BigMac index: %v", err)
+	}
+
+	// Emit event
+	eventJSON, _ := json.Marshal(index)
+	_ = ctx.GetStub().SetEvent("BigMacIndexSet", eventJSON)
+
+	return nil
+}
+
+// GetBigMacIndex returns the BigMac index currently in force for an Ager (public)
+func (s *SmartContract) GetBigMacIndex(ctx contractapi.TransactionContextInterface, agerID string) (*BigMacIndex, error) {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return getBigMacIndexAt(ctx, agerID, txTime)
+}
+
+// GetBigMacIndexHistory returns all BigMac indexes of an Ager ordered by validFrom (public)
+func (s *SmartContract) GetBigMacIndexHistory(ctx contractapi.TransactionContextInterface, agerID string) ([]*BigMacIndex, error) {
+	if err := validateUnitID("ager", agerID); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("bigMacIndex", []string{agerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get BigMac index history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var indexes []*BigMacIndex
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var index BigMacIndex
+		err = json.Unmarshal(queryResponse.Value, &index)
+		if err != nil {
+			return nil, err
+		}
+
+		indexes = append(indexes, &index)
+	}
+
+	return indexes, nil
+}
+
+// ConvertPrice converts an amount given in JEDO, BIGMAC or FIAT with the current index of an Ager (public)
+func (s *SmartContract) ConvertPrice(ctx contractapi.TransactionContextInterface, agerID string, amountStr string, unit string) (*PriceConversion, error) {
+	amount, err := parseAmount(amountStr)
+	if err != nil {
+		return nil, err
+	}
+
+	index, err := s.GetBigMacIndex(ctx, agerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Everything is expressed in BigMac minor units first (1 BigMac = amountScale)
+	var bigMac int64
+	switch strings.ToUpper(unit) {
+	case "JEDO":
+		bigMac, err = mulDiv(amount, amountScale, index.JedoPerBigMac)
+	case "BIGMAC":
+		bigMac = amount
+	case "FIAT", index.FiatCurrency:
+		bigMac, err = mulDiv(amount, amountScale, index.FiatPerBigMac)
+	default:
+		return nil, fmt.Errorf("unknown unit %q (use JEDO, BIGMAC or FIAT)", unit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conversion := &PriceConversion{
+		AgerID:         agerID,
+		BigMac:         formatAmount(bigMac),
+		FiatCurrency:   index.FiatCurrency,
+		IndexValidFrom: index.ValidFrom,
+	}
+
+	// Convert directly from the given unit to avoid compounding rounding errors
+	jedo, fiat := amount, amount
+	switch strings.ToUpper(unit) {
+	case "JEDO":
+		fiat, err = mulDiv(amount, index.FiatPerBigMac, index.JedoPerBigMac)
+	case "BIGMAC":
+		if jedo, err = mulDiv(amount, index.JedoPerBigMac, amountScale); err == nil {
+			fiat, err = mulDiv(amount, index.FiatPerBigMac, amountScale)
+		}
+	default:
+		jedo, err = mulDiv(amount, index.JedoPerBigMac, index.FiatPerBigMac)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	conversion.Jedo = formatAmount(jedo)
+	conversion.Fiat = formatAmount(fiat)
+	return conversion, nil
+}
+
+// getLatestBigMacIndex returns the index of an Ager with the latest validFrom, nil if it has none
+func getLatestBigMacIndex(ctx contractapi.TransactionContextInterface, agerID string) (*BigMacIndex, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("bigMacIndex", []string{agerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get BigMac index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	// Keys are ordered by validFrom, so the last entry is the latest
+	var latest *BigMacIndex
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var index BigMacIndex
+		if err := json.Unmarshal(queryResponse.Value, &index); err != nil {
+			return nil, err
+		}
+		latest = &index
+	}
+	return latest, nil
+}
+
+// getBigMacIndexAt returns the index of an Ager with the latest validFrom not after at
+func getBigMacIndexAt(ctx contractapi.TransactionContextInterface, agerID string, at time.Time) (*BigMacIndex, error) {
+	if err := validateUnitID("ager", agerID); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("bigMacIndex", []string{agerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get BigMac index: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	cutoff := at.UTC().Format(time.RFC3339)
+	var current *BigMacIndex
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var index BigMacIndex
+		if err := json.Unmarshal(queryResponse.Value, &index); err != nil {
+			return nil, err
+		}
+
+		// Keys are ordered by validFrom, so the last match is the one in force
+		if index.ValidFrom > cutoff {
+			break
+		}
+		current = &index
+	}
+
+	if current == nil {
+		return nil, fmt.Errorf("no BigMac index in force for ager %s", agerID)
+	}
+	return current, nil
+}
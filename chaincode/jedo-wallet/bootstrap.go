@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BootstrapMode records whether the network is still bootstrapping.
+// While it is active the admin may create money with Credit and initial wallet balances;
+// afterwards new money only comes from approved public-good proposals and the BTC on-ramp.
+type BootstrapMode struct {
+	DocType string `json:"docType"`
+	Active  bool   `json:"active"`
+	EndedBy string `json:"endedBy,omitempty"`
+	EndedAt string `json:"endedAt,omitempty"`
+}
+
+// bootstrapModeKey returns the state key of the bootstrap mode
+func bootstrapModeKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("config", []string{"bootstrapMode"})
+}
+
+// EndBootstrapMode ends the bootstrap mode for the whole channel for good (Orbis admin only, cannot be undone)
+func (s *SmartContract) EndBootstrapMode(ctx contractapi.TransactionContextInterface) error {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return fmt.Errorf("only an Orbis admin can end the bootstrap mode")
+	}
+
+	mode, err := s.GetBootstrapMode(ctx)
+	if err != nil {
+		return err
+	}
+	if !mode.Active {
+		return fmt.Errorf("bootstrap mode has already ended")
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	mode.Active = false
+	mode.EndedBy = callerID
+	mode.EndedAt = now.Format(time.RFC3339)
+
+	modeJSON, err := json.Marshal(mode)
+	if err != nil {
+		return err
+	}
+
+	key, err := bootstrapModeKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, modeJSON); err != nil {
+		return fmt.Errorf("failed to save bootstrap mode: %v", err)
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"endedBy":   callerID,
+		"timestamp": mode.EndedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("BootstrapModeEnded", eventJSON)
+
+	return nil
+}
+
+// GetBootstrapMode returns whether the bootstrap mode is active (public)
+func (s *SmartContract) GetBootstrapMode(ctx contractapi.TransactionContextInterface) (*BootstrapMode, error) {
+	key, err := bootstrapModeKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	modeJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bootstrap mode: %v", err)
+	}
+	if modeJSON == nil {
+		// Networks start in bootstrap mode
+		return &BootstrapMode{DocType: "bootstrapMode", Active: true}, nil
+	}
+
+	var mode BootstrapMode
+	if err := json.Unmarshal(modeJSON, &mode); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal bootstrap mode: %v", err)
+	}
+
+	return &mode, nil
+}
+
+// requireBootstrapMode fails once the bootstrap mode has ended
+func (s *SmartContract) requireBootstrapMode(ctx contractapi.TransactionContextInterface, action string) error {
+	mode, err := s.GetBootstrapMode(ctx)
+	if err != nil {
+		return err
+	}
+	if !mode.Active {
+		return fmt.Errorf("%s is only allowed in bootstrap mode, new JEDO must be approved as public-good proposal", action)
+	}
+	return nil
+}
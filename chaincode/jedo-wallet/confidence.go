@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Terms of office and confidence votes
+const (
+	officeTermYears      = 4  // Length of a term before the mandatory confidence vote
+	confidenceVotingDays = 30 // Length of a confidence round and of the election that may follow
+)
+
+// StartNoConfidenceVote starts a no-confidence vote against the official of a unit.
+// Eligible humans can challenge their Ager, Ager officials their Regnum and Regnum officials the Orbis.
+func (s *SmartContract) StartNoConfidenceVote(ctx contractapi.TransactionContextInterface, level string, unitID string) (*Proposal, error) {
+	if err := validateLevel(level); err != nil {
+		return nil, err
+	}
+
+	callerID, path, err := getCallerHuman(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("only humans and officials can start a no-confidence vote")
+	}
+
+	switch level {
+	case levelAger:
+		if err := s.requireEligibleVoter(ctx, callerID, path, level, unitID); err != nil {
+			return nil, err
+		}
+	case levelRegnum:
+		if path.Regnum != unitID || !isOfficeHolder(ctx, levelAger, path.Ager) {
+			return nil, fmt.Errorf("only an ager official of regnum %s can start a no-confidence vote against it", unitID)
+		}
+	case levelOrbis:
+		if path.Orbis != unitID || !isOfficeHolder(ctx, levelRegnum, path.Regnum) {
+			return nil, fmt.Errorf("only a regnum official of orbis %s can start a no-confidence vote against it", unitID)
+		}
+	}
+
+	holder, err := getOpenOffice(ctx, level, unitID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	proposalID := fmt.Sprintf("no-confidence.%s.%s.%d", level, unitID, now.Unix())
+	title := fmt.Sprintf("No-confidence vote against %s", holder.HolderID)
+	return s.startOfficeVote(ctx, holder, proposalID, kindNoConfidence, title, callerID)
+}
+
+// StartConfidenceVote starts the mandatory confidence vote once the term of an official has ended (public, deterministic)
+func (s *SmartContract) StartConfidenceVote(ctx contractapi.TransactionContextInterface, level string, unitID string) (*Proposal, error) {
+	if err := validateLevel(level); err != nil {
+		return nil, err
+	}
+
+	holder, err := getOpenOffice(ctx, level, unitID)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	termEnd, err := time.Parse(time.RFC3339, holder.TermEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid term end: %v", err)
+	}
+	if now.Before(termEnd) {
+		return nil, fmt.Errorf("term %d of %s %s ends at %s", holder.Term, level, unitID, holder.TermEnd)
+	}
+
+	proposalID := fmt.Sprintf("confidence.%s.%s.%d", level, unitID, holder.Term)
+	title := fmt.Sprintf("Confidence vote on term %d of %s", holder.Term, holder.HolderID)
+	return s.startOfficeVote(ctx, holder, proposalID, kindConfidence, title, "chaincode")
+}
+
+// getOpenOffice returns the official of a unit if no confidence vote or re-election is running
+func getOpenOffice(ctx contractapi.TransactionContextInterface, level string, unitID string) (*OfficeHolder, error) {
+	holder, err := getOfficeHolder(ctx, level, unitID)
+	if err != nil {
+		return nil, err
+	}
+	if holder == nil {
+		return nil, fmt.Errorf("%s %s has no elected official", level, unitID)
+	}
+	if holder.PendingVoteID != "" {
+		return nil, fmt.Errorf("vote %s on the official of %s %s is still running", holder.PendingVoteID, level, unitID)
+	}
+	if holder.ReElectionID != "" {
+		return nil, fmt.Errorf("election %s for %s %s is still running", holder.ReElectionID, level, unitID)
+	}
+	return holder, nil
+}
+
+// startOfficeVote opens a confidence or no-confidence vote held by the humans of the unit and marks it on the office
+func (s *SmartContract) startOfficeVote(ctx contractapi.TransactionContextInterface, holder *OfficeHolder, proposalID string, kind string, title string, proposerID string) (*Proposal, error) {
+	proposal, err := s.openProposal(ctx, &Proposal{
+		ProposalID: proposalID,
+		Kind:       kind,
+		Level:      holder.Level,
+		UnitID:     holder.UnitID,
+		Title:      title,
+		ProposerID: proposerID,
+		VotingDays: confidenceVotingDays,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	holder.PendingVoteID = proposal.ProposalID
+	if err := putOfficeHolder(ctx, holder); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// applyConfidenceDecision renews the term after a won confidence vote, or opens an election
+// after a passed no-confidence vote or a lost confidence vote
+func (s *SmartContract) applyConfidenceDecision(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	holder, err := getOfficeHolder(ctx, proposal.Level, proposal.UnitID)
+	if err != nil {
+		return err
+	}
+	if holder == nil || holder.PendingVoteID != proposal.ProposalID {
+		return nil
+	}
+	holder.PendingVoteID = ""
+
+	confirmed := proposal.Status == "passed"
+	if proposal.Kind == kindNoConfidence {
+		confirmed = proposal.Status != "passed"
+	}
+
+	switch {
+	case confirmed && proposal.Kind == kindConfidence:
+		termEnd, err := time.Parse(time.RFC3339, holder.TermEnd)
+		if err != nil {
+			return fmt.Errorf("invalid term end: %v", err)
+		}
+		holder.Term++
+		holder.TermStart = holder.TermEnd
+		holder.TermEnd = termEnd.AddDate(officeTermYears, 0, 0).Format(time.RFC3339)
+	case !confirmed:
+		now, err := getTxTime(ctx)
+		if err != nil {
+			return err
+		}
+		electionID := fmt.Sprintf("election.%s.%s.%d", proposal.Level, proposal.UnitID, now.Unix())
+		election, err := s.openElection(ctx, electionID, proposal.Level, proposal.UnitID, confidenceVotingDays, "proposal:"+proposal.ProposalID)
+		if err != nil {
+			return err
+		}
+		holder.ReElectionID = election.ElectionID
+	}
+
+	return putOfficeHolder(ctx, holder)
+}
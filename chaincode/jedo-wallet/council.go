@@ -0,0 +1,916 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Security Council rules of the governance concept
+const (
+	councilSize            = 6 // One seat per Regnum plus the Orbis chair
+	councilTermYears       = 2
+	ratificationDays       = 30 // Executed actions must be ratified within this period
+	ratificationVotingDays = 5  // 3 rounds and 2 cool-downs take 25 days, leaving time to tally the last round before the deadline
+	ratificationPrefix     = "ratification."
+	maxCouncilVotingDays   = 30
+)
+
+// Emergency actions the council can take
+const (
+	actionLockCrossChannel = "lock-cross-channel" // Target: regnum ID, or empty for all Regnums
+	actionSuspendAger      = "suspend-ager"       // Target: ager ID
+	actionPauseFunction    = "pause-function"     // Target: chaincode function name
+)
+
+// councilQuorums are the shares of all council members in percent that must approve in round 1, 2 and 3
+// (round 1 unanimous, then more than 80% and more than 60%)
+var councilQuorums = []int{100, 80, 60}
+
+// unpausableFunctions keep the council and the ratification working while functions are paused
+var unpausableFunctions = map[string]bool{
+	"ProposeCouncilAction":       true,
+	"ApproveCouncilAction":       true,
+	"TallyCouncilAction":         true,
+	"RevertOverdueRatifications": true,
+	"CastVote":                   true,
+	"TallyProposal":              true,
+}
+
+// CouncilMember is the holder of a Security Council seat
+type CouncilMember struct {
+	DocType     string `json:"docType"`
+	Seat        string `json:"seat"` // regnum ID, or orbis for the chair
+	MemberID    string `json:"memberId"`
+	TermStart   string `json:"termStart"`
+	TermEnd     string `json:"termEnd"`
+	AppointedBy string `json:"appointedBy"`
+}
+
+// CouncilAction is an emergency measure approved by the Security Council and ratified afterwards
+type CouncilAction struct {
+	DocType                string         `json:"docType"`
+	ActionID               string         `json:"actionId"`
+	Type                   string         `json:"type"` // lock-cross-channel, suspend-ager, pause-function
+	Target                 string         `json:"target"`
+	Reason                 string         `json:"reason"`
+	DocumentCID            string         `json:"documentCid"` // IPFS CID of the published justification
+	ProposerID             string         `json:"proposerId"`
+	VotingDays             int            `json:"votingDays"` // Length of a round, also used as cool-down between rounds
+	Round                  int            `json:"round"`
+	RoundStart             string         `json:"roundStart"`
+	RoundEnd               string         `json:"roundEnd"`
+	Status                 string         `json:"status"` // voting, rejected, executed, ratified, reverted
+	Results                []CouncilRound `json:"results"`
+	ExecutedAt             string         `json:"executedAt,omitempty"`
+	RatificationDeadline   string         `json:"ratificationDeadline,omitempty"`
+	RatificationProposalID string         `json:"ratificationProposalId,omitempty"`
+	RatificationOverdue    bool           `json:"ratificationOverdue"`
+	CreatedAt              string         `json:"createdAt"`
+	ClosedAt               string         `json:"closedAt,omitempty"`
+	TxID                   string         `json:"txId"`
+}
+
+// CouncilRound is the result of one approval round
+type CouncilRound struct {
+	Round     int    `json:"round"`
+	Quorum    int    `json:"quorum"` // Share of all members in percent
+	Members   int    `json:"members"`
+	Approvals int    `json:"approvals"`
+	Passed    bool   `json:"passed"`
+	ClosedAt  string `json:"closedAt"`
+}
+
+// CouncilApproval is the decision of one council member in one round
+type CouncilApproval struct {
+	DocType   string `json:"docType"`
+	ActionID  string `json:"actionId"`
+	Round     int    `json:"round"`
+	MemberID  string `json:"memberId"`
+	Approve   bool   `json:"approve"`
+	Timestamp string `json:"timestamp"`
+	TxID      string `json:"txId"`
+}
+
+// EmergencyFlag is an active effect of an executed council action
+type EmergencyFlag struct {
+	DocType     string `json:"docType"`
+	Type        string `json:"type"`
+	Target      string `json:"target"`
+	ActionID    string `json:"actionId"`
+	ActiveSince string `json:"activeSince"`
+}
+
+// SetCouncilMember records the holder of a council seat for a 2-year term (Orbis admin only).
+// The council can suspend Agers, so the admin of a single Ager must not appoint it.
+func (s *SmartContract) SetCouncilMember(ctx contractapi.TransactionContextInterface, seat string, memberID string) (*CouncilMember, error) {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can set council members")
+	}
+
+	if err := validateUnitID("seat", seat); err != nil {
+		return nil, err
+	}
+	if _, err := parseOwnerPath(memberID, levelHuman); err != nil {
+		return nil, err
+	}
+
+	members, err := getCouncilMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	occupied := false
+	for _, member := range members {
+		if member.Seat == seat {
+			occupied = true
+		} else if member.MemberID == memberID {
+			return nil, fmt.Errorf("%s already holds council seat %s", memberID, member.Seat)
+		}
+	}
+	if !occupied && len(members) >= councilSize {
+		return nil, fmt.Errorf("the council already has %d seats", councilSize)
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	member := &CouncilMember{
+		DocType:     "councilMember",
+		Seat:        seat,
+		MemberID:    memberID,
+		TermStart:   now.Format(time.RFC3339),
+		TermEnd:     now.AddDate(councilTermYears, 0, 0).Format(time.RFC3339),
+		AppointedBy: callerID,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("councilMember", []string{seat})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	memberJSON, err := json.Marshal(member)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, memberJSON); err != nil {
+		return nil, fmt.Errorf("failed to save council member: %v", err)
+	}
+
+	return member, nil
+}
+
+// RemoveCouncilMember vacates a council seat, e.g. after a resignation (Orbis admin only)
+func (s *SmartContract) RemoveCouncilMember(ctx contractapi.TransactionContextInterface, seat string) error {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return fmt.Errorf("only an Orbis admin can remove council members")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("councilMember", []string{seat})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing == nil {
+		return fmt.Errorf("council seat %s is vacant", seat)
+	}
+
+	return ctx.GetStub().DelState(key)
+}
+
+// GetCouncilMembers returns all council seats (public)
+func (s *SmartContract) GetCouncilMembers(ctx contractapi.TransactionContextInterface) ([]*CouncilMember, error) {
+	return getCouncilMembers(ctx)
+}
+
+// ProposeCouncilAction proposes an emergency action and opens round 1 of the council approval (council members only)
+func (s *SmartContract) ProposeCouncilAction(
+	ctx contractapi.TransactionContextInterface,
+	actionID string,
+	actionType string,
+	target string,
+	reason string,
+	documentCID string,
+	votingDays int,
+) (*CouncilAction, error) {
+	member, err := getCallerCouncilMember(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateUnitID("action", actionID); err != nil {
+		return nil, err
+	}
+	if len(ratificationPrefix+actionID) > 64 {
+		return nil, fmt.Errorf("action ID must not exceed %d characters", 64-len(ratificationPrefix))
+	}
+	switch actionType {
+	case actionLockCrossChannel:
+		if target != "" {
+			if err := validateUnitID("regnum", target); err != nil {
+				return nil, err
+			}
+		}
+	case actionSuspendAger:
+		if err := validateUnitID("ager", target); err != nil {
+			return nil, err
+		}
+	case actionPauseFunction:
+		if target == "" || unpausableFunctions[target] {
+			return nil, fmt.Errorf("function %q cannot be paused", target)
+		}
+	default:
+		return nil, fmt.Errorf("action type must be %s, %s or %s", actionLockCrossChannel, actionSuspendAger, actionPauseFunction)
+	}
+	if strings.TrimSpace(reason) == "" {
+		return nil, fmt.Errorf("reason cannot be empty")
+	}
+	if votingDays < 1 || votingDays > maxCouncilVotingDays {
+		return nil, fmt.Errorf("voting days must be between 1 and %d", maxCouncilVotingDays)
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("councilAction", []string{actionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("council action %s already exists", actionID)
+	}
+
+	// One emergency flag per type and target: a second action would overwrite it and a revert would lift both
+	if err := checkNoCouncilActionFor(ctx, actionType, target); err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	action := &CouncilAction{
+		DocType:     "councilAction",
+		ActionID:    actionID,
+		Type:        actionType,
+		Target:      target,
+		Reason:      reason,
+		DocumentCID: documentCID,
+		ProposerID:  member.MemberID,
+		VotingDays:  votingDays,
+		Round:       1,
+		RoundStart:  now.Format(time.RFC3339),
+		RoundEnd:    now.AddDate(0, 0, votingDays).Format(time.RFC3339),
+		Status:      "voting",
+		Results:     []CouncilRound{},
+		CreatedAt:   now.Format(time.RFC3339),
+		TxID:        ctx.GetStub().GetTxID(),
+	}
+
+	if err := putCouncilAction(ctx, action); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"actionId":  actionID,
+		"type":      actionType,
+		"target":    target,
+		"roundEnd":  action.RoundEnd,
+		"timestamp": action.CreatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("CouncilActionProposed", eventJSON)
+
+	return action, nil
+}
+
+// ApproveCouncilAction records the decision of the calling council member in the current round (one per round).
+// The action is executed as soon as the approvals reach the quorum of the round.
+func (s *SmartContract) ApproveCouncilAction(ctx contractapi.TransactionContextInterface, actionID string, approve bool) (*CouncilAction, error) {
+	member, err := getCallerCouncilMember(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	action, err := getCouncilAction(ctx, actionID)
+	if err != nil {
+		return nil, err
+	}
+	if action.Status != "voting" {
+		return nil, fmt.Errorf("council action %s is %s", actionID, action.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkRoundOpen(action.RoundStart, action.RoundEnd, now); err != nil {
+		return nil, fmt.Errorf("round %d of council action %s %v", action.Round, actionID, err)
+	}
+
+	approvalKey, err := ctx.GetStub().CreateCompositeKey("councilApproval", []string{actionID, strconv.Itoa(action.Round), member.MemberID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(approvalKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("%s has already decided in round %d of council action %s", member.MemberID, action.Round, actionID)
+	}
+
+	approval := CouncilApproval{
+		DocType:   "councilApproval",
+		ActionID:  actionID,
+		Round:     action.Round,
+		MemberID:  member.MemberID,
+		Approve:   approve,
+		Timestamp: now.Format(time.RFC3339),
+		TxID:      ctx.GetStub().GetTxID(),
+	}
+
+	approvalJSON, err := json.Marshal(approval)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(approvalKey, approvalJSON); err != nil {
+		return nil, fmt.Errorf("failed to save council approval: %v", err)
+	}
+
+	if !approve {
+		return action, nil
+	}
+
+	// The new approval is not visible to GetState yet, count it separately
+	result, err := tallyCouncilRound(ctx, action, now)
+	if err != nil {
+		return nil, err
+	}
+	result.Approvals++
+	result.Passed = councilApproved(result.Approvals, result.Members, result.Quorum)
+	if !result.Passed {
+		return action, nil
+	}
+
+	action.Results = append(action.Results, *result)
+	if err := s.executeCouncilAction(ctx, action, now); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// TallyCouncilAction closes a round that ended without enough approvals (public, deterministic).
+// The next round with a lower quorum follows after a cool-down; after round 3 the action is rejected.
+func (s *SmartContract) TallyCouncilAction(ctx contractapi.TransactionContextInterface, actionID string) (*CouncilAction, error) {
+	action, err := getCouncilAction(ctx, actionID)
+	if err != nil {
+		return nil, err
+	}
+	if action.Status != "voting" {
+		return nil, fmt.Errorf("council action %s is already %s", actionID, action.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roundEnd, err := time.Parse(time.RFC3339, action.RoundEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid round end: %v", err)
+	}
+	if now.Before(roundEnd) {
+		return nil, fmt.Errorf("round %d of council action %s ends at %s", action.Round, actionID, action.RoundEnd)
+	}
+
+	result, err := tallyCouncilRound(ctx, action, now)
+	if err != nil {
+		return nil, err
+	}
+	action.Results = append(action.Results, *result)
+
+	switch {
+	case result.Passed:
+		if err := s.executeCouncilAction(ctx, action, now); err != nil {
+			return nil, err
+		}
+		return action, nil
+	case action.Round < len(councilQuorums):
+		nextStart := roundEnd.AddDate(0, 0, action.VotingDays)
+		action.Round++
+		action.RoundStart = nextStart.Format(time.RFC3339)
+		action.RoundEnd = nextStart.AddDate(0, 0, action.VotingDays).Format(time.RFC3339)
+	default:
+		action.Status = "rejected"
+		action.ClosedAt = result.ClosedAt
+	}
+
+	if err := putCouncilAction(ctx, action); err != nil {
+		return nil, err
+	}
+	return action, nil
+}
+
+// GetCouncilAction returns a council action with its rounds and ratification state (public)
+func (s *SmartContract) GetCouncilAction(ctx contractapi.TransactionContextInterface, actionID string) (*CouncilAction, error) {
+	return getCouncilAction(ctx, actionID)
+}
+
+// GetOverdueRatifications returns executed actions whose ratification deadline has passed without approval (public).
+// Actions whose ratification vote fails are reverted automatically, overdue ones by RevertOverdueRatifications.
+func (s *SmartContract) GetOverdueRatifications(ctx contractapi.TransactionContextInterface) ([]*CouncilAction, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return getOverdueCouncilActions(ctx, now)
+}
+
+// RevertOverdueRatifications reverts every executed action that was not ratified by its deadline (public, deterministic):
+// the emergency flag is removed and the action is set to reverted.
+func (s *SmartContract) RevertOverdueRatifications(ctx contractapi.TransactionContextInterface) ([]*CouncilAction, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	overdue, err := getOverdueCouncilActions(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []string{}
+	for _, action := range overdue {
+		if err := revertCouncilAction(ctx, action, now.Format(time.RFC3339)); err != nil {
+			return nil, err
+		}
+		reverted = append(reverted, action.ActionID)
+	}
+
+	// Emit event
+	if len(reverted) > 0 {
+		eventPayload := map[string]interface{}{
+			"actionIds": reverted,
+			"timestamp": now.Format(time.RFC3339),
+		}
+		eventJSON, _ := json.Marshal(eventPayload)
+		_ = ctx.GetStub().SetEvent("CouncilActionsReverted", eventJSON)
+	}
+
+	return overdue, nil
+}
+
+// getOverdueCouncilActions returns the executed actions whose ratification deadline has passed at now
+func getOverdueCouncilActions(ctx contractapi.TransactionContextInterface, now time.Time) ([]*CouncilAction, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("councilAction", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get council actions: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var overdue []*CouncilAction
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var action CouncilAction
+		err = json.Unmarshal(queryResponse.Value, &action)
+		if err != nil {
+			return nil, err
+		}
+
+		if action.Status != "executed" {
+			continue
+		}
+		deadline, err := time.Parse(time.RFC3339, action.RatificationDeadline)
+		if err != nil || now.Before(deadline) {
+			continue
+		}
+
+		action.RatificationOverdue = true
+		overdue = append(overdue, &action)
+	}
+
+	return overdue, nil
+}
+
+// GetEmergencyFlags returns all active effects of executed council actions (public)
+func (s *SmartContract) GetEmergencyFlags(ctx contractapi.TransactionContextInterface) ([]*EmergencyFlag, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("emergency", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get emergency flags: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var flags []*EmergencyFlag
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var flag EmergencyFlag
+		err = json.Unmarshal(queryResponse.Value, &flag)
+		if err != nil {
+			return nil, err
+		}
+
+		flags = append(flags, &flag)
+	}
+
+	return flags, nil
+}
+
+// executeCouncilAction activates the emergency flag and opens the mandatory ratification vote at Orbis level
+func (s *SmartContract) executeCouncilAction(ctx contractapi.TransactionContextInterface, action *CouncilAction, now time.Time) error {
+	flagKey, err := ctx.GetStub().CreateCompositeKey("emergency", []string{action.Type, action.Target})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	flag := EmergencyFlag{
+		DocType:     "emergency",
+		Type:        action.Type,
+		Target:      action.Target,
+		ActionID:    action.ActionID,
+		ActiveSince: now.Format(time.RFC3339),
+	}
+	flagJSON, err := json.Marshal(flag)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(flagKey, flagJSON); err != nil {
+		return fmt.Errorf("failed to save emergency flag: %v", err)
+	}
+
+	proposer, err := parseOwnerPath(action.ProposerID, levelHuman)
+	if err != nil {
+		return err
+	}
+
+	ratification, err := s.openProposal(ctx, &Proposal{
+		ProposalID: ratificationPrefix + action.ActionID,
+		Kind:       kindRatification,
+		Level:      levelOrbis,
+		UnitID:     proposer.Orbis,
+		Title:      fmt.Sprintf("Ratification of council action %s (%s %s)", action.ActionID, action.Type, action.Target),
+		ProposerID: "council",
+		VotingDays: ratificationVotingDays,
+		ActionID:   action.ActionID,
+	})
+	if err != nil {
+		return err
+	}
+
+	action.Status = "executed"
+	action.ExecutedAt = now.Format(time.RFC3339)
+	action.RatificationDeadline = now.AddDate(0, 0, ratificationDays).Format(time.RFC3339)
+	action.RatificationProposalID = ratification.ProposalID
+
+	if err := putCouncilAction(ctx, action); err != nil {
+		return err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"actionId":             action.ActionID,
+		"type":                 action.Type,
+		"target":               action.Target,
+		"ratificationDeadline": action.RatificationDeadline,
+		"timestamp":            action.ExecutedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("CouncilActionExecuted", eventJSON)
+
+	return nil
+}
+
+// applyRatificationDecision keeps a ratified action in force and reverts a rejected one
+func (s *SmartContract) applyRatificationDecision(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	action, err := getCouncilAction(ctx, proposal.ActionID)
+	if err != nil {
+		return err
+	}
+	if action.Status != "executed" {
+		return nil
+	}
+
+	if proposal.Status == "passed" {
+		action.Status = "ratified"
+		action.ClosedAt = proposal.DecidedAt
+		return putCouncilAction(ctx, action)
+	}
+	return revertCouncilAction(ctx, action, proposal.DecidedAt)
+}
+
+// revertCouncilAction lifts the emergency flag of an executed action and marks it reverted.
+// A flag set by another action is left in place.
+func revertCouncilAction(ctx contractapi.TransactionContextInterface, action *CouncilAction, closedAt string) error {
+	flag, err := getEmergencyFlag(ctx, action.Type, action.Target)
+	if err != nil {
+		return err
+	}
+	if flag != nil && flag.ActionID == action.ActionID {
+		flagKey, err := ctx.GetStub().CreateCompositeKey("emergency", []string{action.Type, action.Target})
+		if err != nil {
+			return fmt.Errorf("failed to create composite key: %v", err)
+		}
+		if err := ctx.GetStub().DelState(flagKey); err != nil {
+			return fmt.Errorf("failed to delete emergency flag: %v", err)
+		}
+	}
+
+	action.Status = "reverted"
+	action.ClosedAt = closedAt
+	return putCouncilAction(ctx, action)
+}
+
+// tallyCouncilRound counts the approvals recorded for the current round of an action
+func tallyCouncilRound(ctx contractapi.TransactionContextInterface, action *CouncilAction, now time.Time) (*CouncilRound, error) {
+	members, err := getCouncilMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	active := make(map[string]bool)
+	for _, member := range members {
+		if termEnd, err := time.Parse(time.RFC3339, member.TermEnd); err == nil && now.Before(termEnd) {
+			active[member.MemberID] = true
+		}
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("councilApproval", []string{action.ActionID, strconv.Itoa(action.Round)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get council approvals: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	result := &CouncilRound{
+		Round:    action.Round,
+		Quorum:   councilQuorums[action.Round-1],
+		Members:  len(active),
+		ClosedAt: now.Format(time.RFC3339),
+	}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var approval CouncilApproval
+		err = json.Unmarshal(queryResponse.Value, &approval)
+		if err != nil {
+			return nil, err
+		}
+
+		if approval.Approve && active[approval.MemberID] {
+			result.Approvals++
+		}
+	}
+	result.Passed = councilApproved(result.Approvals, result.Members, result.Quorum)
+
+	return result, nil
+}
+
+// councilApproved reports whether approvals reach the quorum of a round (100 means unanimous)
+func councilApproved(approvals int, members int, quorum int) bool {
+	if members == 0 {
+		return false
+	}
+	if quorum >= 100 {
+		return approvals >= members
+	}
+	return approvals*100 > quorum*members
+}
+
+// getCallerCouncilMember returns the council seat of the caller if the term is running
+func getCallerCouncilMember(ctx contractapi.TransactionContextInterface) (*CouncilMember, error) {
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := getCouncilMembers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, member := range members {
+		if !caller.isOwner(member.MemberID, levelHuman) {
+			continue
+		}
+		termEnd, err := time.Parse(time.RFC3339, member.TermEnd)
+		if err != nil || !now.Before(termEnd) {
+			return nil, fmt.Errorf("council term of %s has ended", caller.CN)
+		}
+		return member, nil
+	}
+	return nil, fmt.Errorf("only council members can act on council actions")
+}
+
+// getCouncilMembers reads all council seats
+func getCouncilMembers(ctx contractapi.TransactionContextInterface) ([]*CouncilMember, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("councilMember", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get council members: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var members []*CouncilMember
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var member CouncilMember
+		err = json.Unmarshal(queryResponse.Value, &member)
+		if err != nil {
+			return nil, err
+		}
+
+		members = append(members, &member)
+	}
+
+	return members, nil
+}
+
+// getCouncilAction reads a council action from the world state
+func getCouncilAction(ctx contractapi.TransactionContextInterface, actionID string) (*CouncilAction, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("councilAction", []string{actionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	actionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if actionJSON == nil {
+		return nil, fmt.Errorf("council action %s does not exist", actionID)
+	}
+
+	var action CouncilAction
+	if err := json.Unmarshal(actionJSON, &action); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal council action: %v", err)
+	}
+	return &action, nil
+}
+
+// putCouncilAction writes a council action to the world state
+func putCouncilAction(ctx contractapi.TransactionContextInterface, action *CouncilAction) error {
+	key, err := ctx.GetStub().CreateCompositeKey("councilAction", []string{action.ActionID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	actionJSON, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, actionJSON); err != nil {
+		return fmt.Errorf("failed to save council action: %v", err)
+	}
+	return nil
+}
+
+// isEmergencyActive reports whether an emergency flag is set for type and target
+func isEmergencyActive(ctx contractapi.TransactionContextInterface, actionType string, target string) (bool, error) {
+	flag, err := getEmergencyFlag(ctx, actionType, target)
+	return flag != nil, err
+}
+
+// getEmergencyFlag reads the emergency flag of a type and target, nil if none is active
+func getEmergencyFlag(ctx contractapi.TransactionContextInterface, actionType string, target string) (*EmergencyFlag, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("emergency", []string{actionType, target})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	flagJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if flagJSON == nil {
+		return nil, nil
+	}
+
+	var flag EmergencyFlag
+	if err := json.Unmarshal(flagJSON, &flag); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal emergency flag: %v", err)
+	}
+	return &flag, nil
+}
+
+// checkNoCouncilActionFor fails while a flag of the type and target is active or another action for it is being voted on
+func checkNoCouncilActionFor(ctx contractapi.TransactionContextInterface, actionType string, target string) error {
+	flag, err := getEmergencyFlag(ctx, actionType, target)
+	if err != nil {
+		return err
+	}
+	if flag != nil {
+		return fmt.Errorf("%s %q is already in force by council action %s", actionType, target, flag.ActionID)
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("councilAction", []string{})
+	if err != nil {
+		return fmt.Errorf("failed to get council actions: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+
+		var action CouncilAction
+		if err := json.Unmarshal(queryResponse.Value, &action); err != nil {
+			return err
+		}
+		if action.Status == "voting" && action.Type == actionType && action.Target == target {
+			return fmt.Errorf("council action %s for %s %q is already being voted on", action.ActionID, actionType, target)
+		}
+	}
+	return nil
+}
+
+// checkFunctionNotPaused rejects calls to functions the council has paused (runs before every transaction)
+func checkFunctionNotPaused(ctx contractapi.TransactionContextInterface) error {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		function = function[i+1:]
+	}
+	if unpausableFunctions[function] {
+		return nil
+	}
+
+	paused, err := isEmergencyActive(ctx, actionPauseFunction, function)
+	if err != nil {
+		return err
+	}
+	if paused {
+		return fmt.Errorf("function %s is paused by the security council", function)
+	}
+	return nil
+}
+
+// checkAgerNotSuspended fails if the council has suspended the Ager
+func checkAgerNotSuspended(ctx contractapi.TransactionContextInterface, agerID string) error {
+	suspended, err := isEmergencyActive(ctx, actionSuspendAger, agerID)
+	if err != nil {
+		return err
+	}
+	if suspended {
+		return fmt.Errorf("ager %s is suspended by the security council", agerID)
+	}
+	return nil
+}
+
+// checkCrossChannelLock fails if a transfer between two Regnums is locked by the council
+func checkCrossChannelLock(ctx contractapi.TransactionContextInterface, from *Wallet, to *Wallet) error {
+	fromPath, err := parseOwnerPath(from.OwnerID, from.OwnerType)
+	if err != nil {
+		return nil
+	}
+	toPath, err := parseOwnerPath(to.OwnerID, to.OwnerType)
+	if err != nil || fromPath.Regnum == toPath.Regnum {
+		return nil
+	}
+
+	for _, target := range []string{"", fromPath.Regnum, toPath.Regnum} {
+		locked, err := isEmergencyActive(ctx, actionLockCrossChannel, target)
+		if err != nil {
+			return err
+		}
+		if locked {
+			return fmt.Errorf("cross-channel transfers between %s and %s are locked by the security council", fromPath.Regnum, toPath.Regnum)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,579 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Election rules of the governance concept
+const (
+	electionRoundsPerCycle = 3  // Rounds without absolute majority before the election is postponed
+	electionOpenRounds     = 2  // Rounds in which new candidates may register
+	electionPostponeDays   = 30 // Reflection period before a postponed election starts again
+)
+
+// Election elects the official of an Orbis, Regnum or Ager by absolute majority
+type Election struct {
+	DocType        string          `json:"docType"`
+	ElectionID     string          `json:"electionId"`
+	Level          string          `json:"level"` // orbis, regnum, ager
+	UnitID         string          `json:"unitId"`
+	VotingDays     int             `json:"votingDays"` // Length of a round, also used as cool-down between rounds
+	Cycle          int             `json:"cycle"`      // Starts at 1, increases with every postponement
+	Round          int             `json:"round"`      // Round within the cycle (1-3)
+	RoundStart     string          `json:"roundStart"`
+	RoundEnd       string          `json:"roundEnd"`
+	Candidates     []string        `json:"candidates"` // Candidates still standing
+	Eliminated     []string        `json:"eliminated"`
+	Status         string          `json:"status"` // voting, elected
+	PostponedUntil string          `json:"postponedUntil,omitempty"`
+	Results        []ElectionRound `json:"results"`
+	ElectedID      string          `json:"electedId,omitempty"`
+	CreatedBy      string          `json:"createdBy"`
+	CreatedAt      string          `json:"createdAt"`
+	DecidedAt      string          `json:"decidedAt,omitempty"`
+	TxID           string          `json:"txId"`
+}
+
+// ElectionRound is the on-chain result of one election round
+type ElectionRound struct {
+	Cycle      int              `json:"cycle"`
+	Round      int              `json:"round"`
+	Votes      []CandidateVotes `json:"votes"`
+	Total      int              `json:"total"`
+	ElectedID  string           `json:"electedId,omitempty"`
+	Eliminated string           `json:"eliminated,omitempty"` // Candidate dropped before the next round
+	Postponed  bool             `json:"postponed"`
+	TalliedAt  string           `json:"talliedAt"`
+}
+
+// CandidateVotes is the number of ballots for one candidate
+type CandidateVotes struct {
+	CandidateID string `json:"candidateId"`
+	Votes       int    `json:"votes"`
+}
+
+// ElectionBallot is the ballot of one human in one round
+type ElectionBallot struct {
+	DocType     string `json:"docType"`
+	ElectionID  string `json:"electionId"`
+	Cycle       int    `json:"cycle"`
+	Round       int    `json:"round"`
+	VoterID     string `json:"voterId"`
+	CandidateID string `json:"candidateId"`
+	Timestamp   string `json:"timestamp"`
+	TxID        string `json:"txId"`
+}
+
+// OfficeHolder is the elected official of an Orbis, Regnum or Ager
+type OfficeHolder struct {
+	DocType       string `json:"docType"`
+	Level         string `json:"level"`
+	UnitID        string `json:"unitId"`
+	HolderID      string `json:"holderId"`
+	ElectionID    string `json:"electionId"`
+	ElectedAt     string `json:"electedAt"`
+	Term          int    `json:"term"` // Term number, increases with every confidence vote won
+	TermStart     string `json:"termStart"`
+	TermEnd       string `json:"termEnd"`
+	PendingVoteID string `json:"pendingVoteId,omitempty"` // Running confidence or no-confidence vote
+	ReElectionID  string `json:"reElectionId,omitempty"`  // Election opened after lost confidence
+}
+
+// CreateElection opens round 1 of an election for the official of a unit
+// (admin of that Ager for an Ager election, Orbis admin for Regnum and Orbis elections)
+func (s *SmartContract) CreateElection(ctx contractapi.TransactionContextInterface, electionID string, level string, unitID string, votingDays int) (*Election, error) {
+	// Admin check
+	if level == levelAger {
+		if !isAgerAdmin(ctx, unitID) {
+			return nil, fmt.Errorf("only an admin of ager %s can create its elections", unitID)
+		}
+	} else if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can create %s elections", level)
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.openElection(ctx, electionID, level, unitID, votingDays, callerID)
+}
+
+// openElection creates an election whose round 1 starts now
+func (s *SmartContract) openElection(ctx contractapi.TransactionContextInterface, electionID string, level string, unitID string, votingDays int, createdBy string) (*Election, error) {
+	if err := validateUnitID("election", electionID); err != nil {
+		return nil, err
+	}
+	if err := validateLevel(level); err != nil {
+		return nil, err
+	}
+	if err := validateUnitID(level, unitID); err != nil {
+		return nil, err
+	}
+	if votingDays < 1 || votingDays > 365 {
+		return nil, fmt.Errorf("voting days must be between 1 and 365")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("election", []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("election %s already exists", electionID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	election := &Election{
+		DocType:    "election",
+		ElectionID: electionID,
+		Level:      level,
+		UnitID:     unitID,
+		VotingDays: votingDays,
+		Cycle:      1,
+		Round:      1,
+		RoundStart: now.Format(time.RFC3339),
+		RoundEnd:   now.AddDate(0, 0, votingDays).Format(time.RFC3339),
+		Candidates: []string{},
+		Eliminated: []string{},
+		Status:     "voting",
+		Results:    []ElectionRound{},
+		CreatedBy:  createdBy,
+		CreatedAt:  now.Format(time.RFC3339),
+		TxID:       ctx.GetStub().GetTxID(),
+	}
+
+	if err := putElection(ctx, election); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"electionId": electionID,
+		"level":      level,
+		"unitId":     unitID,
+		"roundEnd":   election.RoundEnd,
+		"timestamp":  election.CreatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("ElectionCreated", eventJSON)
+
+	return election, nil
+}
+
+// RegisterCandidate registers the calling human as candidate (eligible humans of the unit, rounds 1-2 only)
+func (s *SmartContract) RegisterCandidate(ctx contractapi.TransactionContextInterface, electionID string) error {
+	candidateID, path, err := getCallerHuman(ctx)
+	if err != nil {
+		return err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if election.Status != "voting" {
+		return fmt.Errorf("election %s is %s", electionID, election.Status)
+	}
+	if election.Round > electionOpenRounds {
+		return fmt.Errorf("candidates can only register in rounds 1-%d", electionOpenRounds)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	roundEnd, err := time.Parse(time.RFC3339, election.RoundEnd)
+	if err != nil {
+		return fmt.Errorf("invalid round end: %v", err)
+	}
+	if !now.Before(roundEnd) {
+		return fmt.Errorf("round %d of election %s closed at %s", election.Round, electionID, election.RoundEnd)
+	}
+
+	if err := s.requireEligibleVoter(ctx, candidateID, path, election.Level, election.UnitID); err != nil {
+		return err
+	}
+	for _, id := range election.Candidates {
+		if id == candidateID {
+			return fmt.Errorf("%s is already a candidate", candidateID)
+		}
+	}
+
+	election.Candidates = append(election.Candidates, candidateID)
+	sort.Strings(election.Candidates)
+
+	return putElection(ctx, election)
+}
+
+// CastBallot records the ballot of the calling human for a candidate in the current round (eligible humans of the unit, one ballot per round)
+func (s *SmartContract) CastBallot(ctx contractapi.TransactionContextInterface, electionID string, candidateID string) error {
+	voterID, path, err := getCallerHuman(ctx)
+	if err != nil {
+		return err
+	}
+
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return err
+	}
+	if election.Status != "voting" {
+		return fmt.Errorf("election %s is %s", electionID, election.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkRoundOpen(election.RoundStart, election.RoundEnd, now); err != nil {
+		return fmt.Errorf("round %d of election %s %v", election.Round, electionID, err)
+	}
+
+	standing := false
+	for _, id := range election.Candidates {
+		if id == candidateID {
+			standing = true
+			break
+		}
+	}
+	if !standing {
+		return fmt.Errorf("%s is not a candidate in election %s", candidateID, electionID)
+	}
+
+	if err := s.requireEligibleVoter(ctx, voterID, path, election.Level, election.UnitID); err != nil {
+		return err
+	}
+
+	// One human, one ballot per round
+	ballotKey, err := ctx.GetStub().CreateCompositeKey("electionBallot", []string{electionID, strconv.Itoa(election.Cycle), strconv.Itoa(election.Round), voterID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(ballotKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("%s has already voted in round %d of election %s", voterID, election.Round, electionID)
+	}
+
+	ballot := ElectionBallot{
+		DocType:     "electionBallot",
+		ElectionID:  electionID,
+		Cycle:       election.Cycle,
+		Round:       election.Round,
+		VoterID:     voterID,
+		CandidateID: candidateID,
+		Timestamp:   now.Format(time.RFC3339),
+		TxID:        ctx.GetStub().GetTxID(),
+	}
+
+	ballotJSON, err := json.Marshal(ballot)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(ballotKey, ballotJSON)
+}
+
+// TallyElection counts the ballots of the current round once it has ended and moves the election on (public, deterministic).
+// A candidate with more than 50% is elected. Otherwise the next round follows after a cool-down; from round 3 the
+// candidate with the fewest votes is dropped. After three rounds without majority the election restarts after 30 days
+// with fresh candidatures.
+func (s *SmartContract) TallyElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	election, err := getElection(ctx, electionID)
+	if err != nil {
+		return nil, err
+	}
+	if election.Status != "voting" {
+		return nil, fmt.Errorf("election %s is already %s", electionID, election.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roundEnd, err := time.Parse(time.RFC3339, election.RoundEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid round end: %v", err)
+	}
+	if now.Before(roundEnd) {
+		return nil, fmt.Errorf("round %d of election %s ends at %s", election.Round, electionID, election.RoundEnd)
+	}
+
+	ballots, err := getElectionBallots(ctx, electionID, election.Cycle, election.Round)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, candidateID := range election.Candidates {
+		counts[candidateID] = 0
+	}
+	for _, ballot := range ballots {
+		counts[ballot.CandidateID]++
+	}
+
+	result := ElectionRound{
+		Cycle:     election.Cycle,
+		Round:     election.Round,
+		Votes:     []CandidateVotes{},
+		Total:     len(ballots),
+		TalliedAt: now.Format(time.RFC3339),
+	}
+	for _, candidateID := range election.Candidates {
+		result.Votes = append(result.Votes, CandidateVotes{CandidateID: candidateID, Votes: counts[candidateID]})
+		if counts[candidateID]*2 > result.Total {
+			result.ElectedID = candidateID
+		}
+	}
+
+	switch {
+	case result.ElectedID != "":
+		election.Status = "elected"
+		election.ElectedID = result.ElectedID
+		election.DecidedAt = result.TalliedAt
+		holder := &OfficeHolder{
+			DocType:    "officeHolder",
+			Level:      election.Level,
+			UnitID:     election.UnitID,
+			HolderID:   election.ElectedID,
+			ElectionID: election.ElectionID,
+			ElectedAt:  result.TalliedAt,
+			Term:       1,
+			TermStart:  result.TalliedAt,
+			TermEnd:    now.AddDate(officeTermYears, 0, 0).Format(time.RFC3339),
+		}
+		if err := putOfficeHolder(ctx, holder); err != nil {
+			return nil, err
+		}
+	case election.Round < electionRoundsPerCycle:
+		election.Round++
+		nextStart := roundEnd.AddDate(0, 0, election.VotingDays)
+		election.RoundStart = nextStart.Format(time.RFC3339)
+		election.RoundEnd = nextStart.AddDate(0, 0, election.VotingDays).Format(time.RFC3339)
+
+		// From round 3 on the weakest candidate drops out, as long as more than two remain
+		if election.Round > electionOpenRounds && len(election.Candidates) > 2 {
+			result.Eliminated = weakestCandidate(result.Votes)
+			election.Eliminated = append(election.Eliminated, result.Eliminated)
+			remaining := []string{}
+			for _, candidateID := range election.Candidates {
+				if candidateID != result.Eliminated {
+					remaining = append(remaining, candidateID)
+				}
+			}
+			election.Candidates = remaining
+		}
+	default:
+		// Postponed: fresh candidatures for a new cycle after the reflection period
+		result.Postponed = true
+		restart := roundEnd.AddDate(0, 0, electionPostponeDays)
+		election.Cycle++
+		election.Round = 1
+		election.Candidates = []string{}
+		election.Eliminated = []string{}
+		election.PostponedUntil = restart.Format(time.RFC3339)
+		election.RoundStart = election.PostponedUntil
+		election.RoundEnd = restart.AddDate(0, 0, election.VotingDays).Format(time.RFC3339)
+	}
+
+	election.Results = append(election.Results, result)
+	if err := putElection(ctx, election); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"electionId": electionID,
+		"cycle":      result.Cycle,
+		"round":      result.Round,
+		"electedId":  result.ElectedID,
+		"eliminated": result.Eliminated,
+		"postponed":  result.Postponed,
+		"timestamp":  result.TalliedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("ElectionTallied", eventJSON)
+
+	return election, nil
+}
+
+// weakestCandidate returns the candidate with the fewest votes; ties drop the candidate sorting last
+func weakestCandidate(votes []CandidateVotes) string {
+	weakest := votes[0]
+	for _, v := range votes[1:] {
+		if v.Votes < weakest.Votes || (v.Votes == weakest.Votes && v.CandidateID > weakest.CandidateID) {
+			weakest = v
+		}
+	}
+	return weakest.CandidateID
+}
+
+// GetElection returns an election with its round results (public)
+func (s *SmartContract) GetElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	return getElection(ctx, electionID)
+}
+
+// GetElections returns all elections, optionally filtered by level, unit and status (public)
+func (s *SmartContract) GetElections(ctx contractapi.TransactionContextInterface, level string, unitID string, status string) ([]*Election, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("election", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get elections: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var elections []*Election
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var election Election
+		err = json.Unmarshal(queryResponse.Value, &election)
+		if err != nil {
+			return nil, err
+		}
+
+		if (level != "" && election.Level != level) || (unitID != "" && election.UnitID != unitID) || (status != "" && election.Status != status) {
+			continue
+		}
+
+		elections = append(elections, &election)
+	}
+
+	return elections, nil
+}
+
+// GetOfficeHolder returns the elected official of a unit (public)
+func (s *SmartContract) GetOfficeHolder(ctx contractapi.TransactionContextInterface, level string, unitID string) (*OfficeHolder, error) {
+	holder, err := getOfficeHolder(ctx, level, unitID)
+	if err != nil {
+		return nil, err
+	}
+	if holder == nil {
+		return nil, fmt.Errorf("%s %s has no elected official", level, unitID)
+	}
+	return holder, nil
+}
+
+// getElection reads an election from the world state
+func getElection(ctx contractapi.TransactionContextInterface, electionID string) (*Election, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("election", []string{electionID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	electionJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if electionJSON == nil {
+		return nil, fmt.Errorf("election %s does not exist", electionID)
+	}
+
+	var election Election
+	if err := json.Unmarshal(electionJSON, &election); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal election: %v", err)
+	}
+	return &election, nil
+}
+
+// putElection writes an election to the world state
+func putElection(ctx contractapi.TransactionContextInterface, election *Election) error {
+	key, err := ctx.GetStub().CreateCompositeKey("election", []string{election.ElectionID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	electionJSON, err := json.Marshal(election)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, electionJSON); err != nil {
+		return fmt.Errorf("failed to save election: %v", err)
+	}
+	return nil
+}
+
+// getElectionBallots reads all ballots of one round of an election
+func getElectionBallots(ctx contractapi.TransactionContextInterface, electionID string, cycle int, round int) ([]*ElectionBallot, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("electionBallot", []string{electionID, strconv.Itoa(cycle), strconv.Itoa(round)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get ballots: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var ballots []*ElectionBallot
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var ballot ElectionBallot
+		err = json.Unmarshal(queryResponse.Value, &ballot)
+		if err != nil {
+			return nil, err
+		}
+
+		ballots = append(ballots, &ballot)
+	}
+
+	return ballots, nil
+}
+
+// getOfficeHolder reads the elected official of a unit, or nil if there is none
+func getOfficeHolder(ctx contractapi.TransactionContextInterface, level string, unitID string) (*OfficeHolder, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("officeHolder", []string{level, unitID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	holderJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if holderJSON == nil {
+		return nil, nil
+	}
+
+	var holder OfficeHolder
+	if err := json.Unmarshal(holderJSON, &holder); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal office holder: %v", err)
+	}
+	return &holder, nil
+}
+
+// putOfficeHolder writes the official of a unit to the world state
+func putOfficeHolder(ctx contractapi.TransactionContextInterface, holder *OfficeHolder) error {
+	key, err := ctx.GetStub().CreateCompositeKey("officeHolder", []string{holder.Level, holder.UnitID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	holderJSON, err := json.Marshal(holder)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, holderJSON); err != nil {
+		return fmt.Errorf("failed to save office holder: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// activityMonthFormat is the layout of the months in OwnerActivity
+const activityMonthFormat = "2006-01"
+
+// VotingOrdinance holds the voting-right thresholds an Ager sets by ordinance
+type VotingOrdinance struct {
+	DocType          string `json:"docType"`
+	AgerID           string `json:"agerId"`
+	MinBalance       int64  `json:"minBalance"`       // Minimum JEDO holding in minor units
+	MinTransactions  int    `json:"minTransactions"`  // Minimum number of transfers within the activity window
+	ActivityMonths   int    `json:"activityMonths"`   // Length of the activity window
+	MembershipMonths int    `json:"membershipMonths"` // Minimum membership in the Ager
+	UpdatedBy        string `json:"updatedBy"`
+	UpdatedAt        string `json:"updatedAt"`
+}
+
+// Membership records when a human joined an Ager
+type Membership struct {
+	DocType  string `json:"docType"`
+	OwnerID  string `json:"ownerId"`
+	AgerID   string `json:"agerId"`
+	JoinedAt string `json:"joinedAt"` // RFC3339 timestamp
+}
+
+// OwnerActivity counts the transfers of a human per calendar month under a key of its own, so the eligibility
+// check in CastVote and CreateProposal reads it with GetState instead of a rich query that is not re-validated at commit.
+// Transfers between wallets of the same owner are not counted, a TransferBatch counts once per owner.
+type OwnerActivity struct {
+	DocType   string         `json:"docType"`
+	OwnerID   string         `json:"ownerId"`
+	Transfers map[string]int `json:"transfers"` // Transfers by month (YYYY-MM)
+	UpdatedAt string         `json:"updatedAt"`
+}
+
+// VoterEligibility is the answer of a voting-right check
+type VoterEligibility struct {
+	OwnerID        string   `json:"ownerId"`
+	AgerID         string   `json:"agerId"`
+	Eligible       bool     `json:"eligible"`
+	FailedCriteria []string `json:"failedCriteria"` // balance, activity, membership, blocked
+	CheckedAt      string   `json:"checkedAt"`
+}
+
+// defaultVotingOrdinance returns the thresholds of the governance concept (≥10 JEDO, ≥12 transactions in 12 months, ≥6 months membership)
+func defaultVotingOrdinance(agerID string) *VotingOrdinance {
+	return &VotingOrdinance{
+		DocType:          "votingOrdinance",
+		AgerID:           agerID,
+		MinBalance:       10 * amountScale,
+		MinTransactions:  12,
+		ActivityMonths:   12,
+		MembershipMonths: 6,
+	}
+}
+
+// SetVotingOrdinance sets the voting-right thresholds of an Ager (admin of that Ager only)
+func (s *SmartContract) SetVotingOrdinance(
+	ctx contractapi.TransactionContextInterface,
+	agerID string,
+	minBalance string,
+	minTransactions int,
+	activityMonths int,
+	membershipMonths int,
+) error {
+	// Admin check, an admin of another Ager's MSP must not change who votes here
+	if !isAgerAdmin(ctx, agerID) {
+		return fmt.Errorf("only an admin of ager %s can set its voting ordinance", agerID)
+	}
+
+	if err := validateUnitID("ager", agerID); err != nil {
+		return err
+	}
+	minBalanceMinor, err := parseAmount(minBalance)
+	if err != nil {
+		return fmt.Errorf("invalid minimum balance: %v", err)
+	}
+	if minTransactions < 0 || activityMonths < 0 || membershipMonths < 0 {
+		return fmt.Errorf("thresholds cannot be negative")
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	ordinance := VotingOrdinance{
+		DocType:          "votingOrdinance",
+		AgerID:           agerID,
+		MinBalance:       minBalanceMinor,
+		MinTransactions:  minTransactions,
+		ActivityMonths:   activityMonths,
+		MembershipMonths: membershipMonths,
+		UpdatedBy:        callerID,
+		UpdatedAt:        now.Format(time.RFC3339),
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("votingOrdinance", []string{agerID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	ordinanceJSON, err := json.Marshal(ordinance)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, ordinanceJSON)
+}
+
+// GetVotingOrdinance returns the voting-right thresholds of an Ager, or the defaults if none were set (public)
+func (s *SmartContract) GetVotingOrdinance(ctx contractapi.TransactionContextInterface, agerID string) (*VotingOrdinance, error) {
+	if err := validateUnitID("ager", agerID); err != nil {
+		return nil, err
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("votingOrdinance", []string{agerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	ordinanceJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if ordinanceJSON == nil {
+		return defaultVotingOrdinance(agerID), nil
+	}
+
+	var ordinance VotingOrdinance
+	if err := json.Unmarshal(ordinanceJSON, &ordinance); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal voting ordinance: %v", err)
+	}
+	return &ordinance, nil
+}
+
+// RecordMembership sets the date a human joined an Ager, e.g. for humans registered before this was tracked
+// (admin of the human's Ager only). A recorded membership is changed only by CorrectMembership.
+func (s *SmartContract) RecordMembership(ctx contractapi.TransactionContextInterface, ownerID string, joinedAt string) error {
+	joined, err := authorizeMembershipChange(ctx, ownerID, joinedAt)
+	if err != nil {
+		return err
+	}
+
+	existing, err := getMembership(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("membership of %s is already recorded since %s, use CorrectMembership", ownerID, existing.JoinedAt)
+	}
+
+	return putMembership(ctx, ownerID, joined)
+}
+
+// CorrectMembership overwrites the recorded join date of a human (admin of the human's Ager only).
+// The correction and its reason are published as event "MembershipCorrected", since the date decides the voting right.
+func (s *SmartContract) CorrectMembership(ctx contractapi.TransactionContextInterface, ownerID string, joinedAt string, reason string) error {
+	joined, err := authorizeMembershipChange(ctx, ownerID, joinedAt)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("a correction needs a reason")
+	}
+
+	existing, err := getMembership(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("no membership recorded for %s, use RecordMembership", ownerID)
+	}
+
+	if err := putMembership(ctx, ownerID, joined); err != nil {
+		return err
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"ownerId":          ownerID,
+		"agerId":           existing.AgerID,
+		"previousJoinedAt": existing.JoinedAt,
+		"joinedAt":         joined,
+		"reason":           reason,
+		"correctedBy":      callerID,
+		"txId":             ctx.GetStub().GetTxID(),
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("MembershipCorrected", eventJSON)
+
+	return nil
+}
+
+// authorizeMembershipChange checks that the caller administers the human's Ager and returns the normalized join date,
+// which must not lie after the transaction time
+func authorizeMembershipChange(ctx contractapi.TransactionContextInterface, ownerID string, joinedAt string) (string, error) {
+	path, err := parseOwnerPath(ownerID, levelHuman)
+	if err != nil {
+		return "", err
+	}
+
+	// Admin check
+	if !isAgerAdmin(ctx, path.Ager) {
+		return "", fmt.Errorf("only an admin of ager %s can record its memberships", path.Ager)
+	}
+
+	joined, err := time.Parse(time.RFC3339, joinedAt)
+	if err != nil {
+		return "", fmt.Errorf("invalid join date: %v", err)
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	if joined.After(now) {
+		return "", fmt.Errorf("join date %s lies after the transaction time", joinedAt)
+	}
+	return joined.UTC().Format(time.RFC3339), nil
+}
+
+// GetMembership returns the Ager membership of a human (public)
+func (s *SmartContract) GetMembership(ctx contractapi.TransactionContextInterface, ownerID string) (*Membership, error) {
+	membership, err := getMembership(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if membership == nil {
+		return nil, fmt.Errorf("no membership recorded for %s", ownerID)
+	}
+	return membership, nil
+}
+
+// IsEligibleVoter checks the voting right of a human against the ordinance of the Ager (only that human or admin)
+func (s *SmartContract) IsEligibleVoter(ctx contractapi.TransactionContextInterface, ownerID string) (*VoterEligibility, error) {
+	callerRole, err := getCallerRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if callerRole != "admin" && !caller.isOwner(ownerID, levelHuman) {
+		return nil, fmt.Errorf("you can only check your own voting eligibility")
+	}
+
+	return s.checkVoterEligibility(ctx, ownerID)
+}
+
+// checkVoterEligibility evaluates the voting right of a human from the ledger (internal, no access control)
+func (s *SmartContract) checkVoterEligibility(ctx contractapi.TransactionContextInterface, ownerID string) (*VoterEligibility, error) {
+	path, err := parseOwnerPath(ownerID, levelHuman)
+	if err != nil {
+		return nil, err
+	}
+
+	ordinance, err := s.GetVotingOrdinance(ctx, path.Ager)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VoterEligibility{
+		OwnerID:        ownerID,
+		AgerID:         path.Ager,
+		FailedCriteria: []string{},
+		CheckedAt:      now.Format(time.RFC3339),
+	}
+
+	wallets, err := getOwnerWallets(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Blocked humans (unpaid taxes) lose their voting right until the arrears are paid
+	var balance int64
+	blocked := false
+	for _, wallet := range wallets {
+		if wallet.Status == "blocked" {
+			blocked = true
+		}
+		if balance, err = addAmounts(balance, wallet.Balance); err != nil {
+			return nil, err
+		}
+	}
+
+	if balance < ordinance.MinBalance {
+		result.FailedCriteria = append(result.FailedCriteria, "balance")
+	}
+
+	// Count the transfers of the current and the preceding calendar months of the activity window
+	activity, err := getOwnerActivity(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	transfers := 0
+	if ordinance.ActivityMonths > 0 {
+		windowStart := time.Date(now.Year(), now.Month()-time.Month(ordinance.ActivityMonths-1), 1, 0, 0, 0, 0, time.UTC).Format(activityMonthFormat)
+		for month, count := range activity.Transfers {
+			if month >= windowStart {
+				transfers += count
+			}
+		}
+	}
+
+	if transfers < ordinance.MinTransactions {
+		result.FailedCriteria = append(result.FailedCriteria, "activity")
+	}
+
+	membership, err := getMembership(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	memberSince := now.AddDate(0, -ordinance.MembershipMonths, 0)
+	joinedOK := false
+	if membership != nil && membership.AgerID == path.Ager {
+		joined, err := time.Parse(time.RFC3339, membership.JoinedAt)
+		joinedOK = err == nil && !joined.After(memberSince)
+	}
+	if !joinedOK {
+		result.FailedCriteria = append(result.FailedCriteria, "membership")
+	}
+
+	if blocked {
+		result.FailedCriteria = append(result.FailedCriteria, "blocked")
+	}
+
+	result.Eligible = len(result.FailedCriteria) == 0
+	return result, nil
+}
+
+// getMembership reads the Ager membership of a human, or nil if none is recorded
+func getMembership(ctx contractapi.TransactionContextInterface, ownerID string) (*Membership, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("membership", []string{ownerID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	membershipJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if membershipJSON == nil {
+		return nil, nil
+	}
+
+	var membership Membership
+	if err := json.Unmarshal(membershipJSON, &membership); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal membership: %v", err)
+	}
+	return &membership, nil
+}
+
+// putMembership records that a human joined the Ager of the owner ID at joinedAt
+func putMembership(ctx contractapi.TransactionContextInterface, ownerID string, joinedAt string) error {
+	path, err := parseOwnerPath(ownerID, levelHuman)
+	if err != nil {
+		return err
+	}
+
+	membership := Membership{
+		DocType:  "membership",
+		OwnerID:  ownerID,
+		AgerID:   path.Ager,
+		JoinedAt: joinedAt,
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("membership", []string{ownerID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	membershipJSON, err := json.Marshal(membership)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, membershipJSON); err != nil {
+		return fmt.Errorf("failed to save membership: %v", err)
+	}
+	return addMemberUnit(ctx, path)
+}
+
+// recordFirstMembership records the join date of a human on the first wallet, owner IDs outside the hierarchy are skipped
+func recordFirstMembership(ctx contractapi.TransactionContextInterface, ownerID string) error {
+	if _, err := parseOwnerPath(ownerID, levelHuman); err != nil {
+		return nil
+	}
+
+	membership, err := getMembership(ctx, ownerID)
+	if err != nil || membership != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	return putMembership(ctx, ownerID, now.Format(time.RFC3339))
+}
+
+// ownerActivityKey returns the state key of the transfer activity of an owner
+func ownerActivityKey(ctx contractapi.TransactionContextInterface, ownerID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("ownerActivity", []string{ownerID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// getOwnerActivity reads the transfer activity of an owner, empty if none is recorded
+func getOwnerActivity(ctx contractapi.TransactionContextInterface, ownerID string) (*OwnerActivity, error) {
+	key, err := ownerActivityKey(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	activityJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read activity of owner %s: %v", ownerID, err)
+	}
+	activity := &OwnerActivity{DocType: "ownerActivity", OwnerID: ownerID}
+	if activityJSON != nil {
+		if err := json.Unmarshal(activityJSON, activity); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal activity of owner %s: %v", ownerID, err)
+		}
+	}
+	if activity.Transfers == nil {
+		activity.Transfers = make(map[string]int)
+	}
+	return activity, nil
+}
+
+// putOwnerActivity writes the transfer activity of an owner
+func putOwnerActivity(ctx contractapi.TransactionContextInterface, activity *OwnerActivity) error {
+	key, err := ownerActivityKey(ctx, activity.OwnerID)
+	if err != nil {
+		return err
+	}
+
+	activityJSON, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, activityJSON); err != nil {
+		return fmt.Errorf("failed to save activity of owner %s: %v", activity.OwnerID, err)
+	}
+	return nil
+}
+
+// recordTransferActivity counts one transfer in the current month for each human owner of the wallets, every owner once
+// since the activity may be written only once per transaction. Transfers between wallets of one owner must not be passed.
+func recordTransferActivity(ctx contractapi.TransactionContextInterface, wallets ...*Wallet) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	month := now.Format(activityMonthFormat)
+
+	counted := make(map[string]bool)
+	for _, wallet := range wallets {
+		if (wallet.OwnerType != "" && wallet.OwnerType != levelHuman) || counted[wallet.OwnerID] {
+			continue
+		}
+		counted[wallet.OwnerID] = true
+
+		activity, err := getOwnerActivity(ctx, wallet.OwnerID)
+		if err != nil {
+			return err
+		}
+		activity.Transfers[month]++
+		activity.UpdatedAt = now.Format(time.RFC3339)
+		if err := putOwnerActivity(ctx, activity); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// WalletEndorsementPolicy describes the key-level endorsement policy of a wallet.
+// Every change of the wallet must be endorsed by a peer of each listed organization (MSP ID = Ager name).
+type WalletEndorsementPolicy struct {
+	WalletID string   `json:"walletId"`
+	Orgs     []string `json:"orgs"`
+	Role     string   `json:"role"`
+	Default  []string `json:"default,omitempty"` // Organizations applied by ResetWalletEndorsementPolicy
+}
+
+// AddWalletEndorsingOrg requires an additional organization to endorse changes of a wallet (admin of the owning Ager only)
+func (s *SmartContract) AddWalletEndorsingOrg(ctx contractapi.TransactionContextInterface, walletID string, mspID string) error {
+	if err := validateUnitID("MSP", mspID); err != nil {
+		return err
+	}
+	if _, err := s.authorizeWalletEndorsement(ctx, walletID); err != nil {
+		return err
+	}
+
+	orgs, err := getWalletEndorsingOrgs(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	for _, org := range orgs {
+		if org == mspID {
+			return fmt.Errorf("%s already endorses wallet %s", mspID, walletID)
+		}
+	}
+
+	return changeWalletEndorsingOrgs(ctx, walletID, append(orgs, mspID))
+}
+
+// RemoveWalletEndorsingOrg no longer requires an organization to endorse changes of a wallet (admin of the owning Ager only).
+// The owning Ager cannot be removed, so the wallet never leaves the control of its peers.
+func (s *SmartContract) RemoveWalletEndorsingOrg(ctx contractapi.TransactionContextInterface, walletID string, mspID string) error {
+	wallet, err := s.authorizeWalletEndorsement(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	for _, org := range walletDefaultOrgs(wallet) {
+		if org == mspID {
+			return fmt.Errorf("%s owns wallet %s and cannot be removed from its endorsers", mspID, walletID)
+		}
+	}
+
+	orgs, err := getWalletEndorsingOrgs(ctx, walletID)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]string, 0, len(orgs))
+	for _, org := range orgs {
+		if org != mspID {
+			remaining = append(remaining, org)
+		}
+	}
+	if len(remaining) == len(orgs) {
+		return fmt.Errorf("%s does not endorse wallet %s", mspID, walletID)
+	}
+	if len(remaining) == 0 {
+		return fmt.Errorf("wallet %s needs at least one endorsing organization", walletID)
+	}
+
+	return changeWalletEndorsingOrgs(ctx, walletID, remaining)
+}
+
+// ResetWalletEndorsementPolicy sets the policy of a wallet back to its owning Ager
+// (admin of the owning Ager only, also for wallets created before key-level policies)
+func (s *SmartContract) ResetWalletEndorsementPolicy(ctx contractapi.TransactionContextInterface, walletID string) error {
+	wallet, err := s.authorizeWalletEndorsement(ctx, walletID)
+	if err != nil {
+		return err
+	}
+
+	orgs := walletDefaultOrgs(wallet)
+	if len(orgs) == 0 {
+		return fmt.Errorf("wallet %s has no owning ager", walletID)
+	}
+	return changeWalletEndorsingOrgs(ctx, walletID, orgs)
+}
+
+// authorizeWalletEndorsement reads a wallet whose policy the caller may change: an admin of the owning Ager,
+// or an Orbis admin for Regnum and Orbis treasuries. An admin of another Ager must not take over its endorsement.
+func (s *SmartContract) authorizeWalletEndorsement(ctx contractapi.TransactionContextInterface, walletID string) (*Wallet, error) {
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Admin check
+	agerID := walletAger(wallet)
+	if agerID == "" {
+		if !isOrbisAdmin(ctx) {
+			return nil, fmt.Errorf("only an Orbis admin can manage the endorsement policy of wallet %s", walletID)
+		}
+	} else if !isAgerAdmin(ctx, agerID) {
+		return nil, fmt.Errorf("only an admin of ager %s can manage the endorsement policy of wallet %s", agerID, walletID)
+	}
+	return wallet, nil
+}
+
+// GetWalletEndorsementPolicy returns the key-level endorsement policy of a wallet (admin only)
+func (s *SmartContract) GetWalletEndorsementPolicy(ctx contractapi.TransactionContextInterface, walletID string) (*WalletEndorsementPolicy, error) {
+	// Admin check
+	if !isAdmin(ctx) {
+		return nil, fmt.Errorf("only admin can inspect endorsement policies")
+	}
+
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs, err := getWalletEndorsingOrgs(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WalletEndorsementPolicy{
+		WalletID: walletID,
+		Orgs:     orgs,
+		Role:     string(statebased.RoleTypePeer),
+		Default:  walletDefaultOrgs(wallet),
+	}, nil
+}
+
+// applyWalletEndorsementPolicy binds a new wallet to the peers of its owning Ager.
+// Regnum and Orbis treasuries have no single Ager and keep the chaincode-level policy.
+func applyWalletEndorsementPolicy(ctx contractapi.TransactionContextInterface, wallet *Wallet) error {
+	orgs := walletDefaultOrgs(wallet)
+	if len(orgs) == 0 {
+		return nil
+	}
+	return setWalletEndorsingOrgs(ctx, wallet.WalletID, orgs)
+}
+
+// walletDefaultOrgs returns the MSP ID of the Ager owning a wallet, nil if there is none
+func walletDefaultOrgs(wallet *Wallet) []string {
+	agerID := walletAger(wallet)
+	if agerID == "" {
+		return nil
+	}
+	return []string{agerID}
+}
+
+// getWalletEndorsingOrgs returns the organizations of the key-level policy of a wallet (empty without policy)
+func getWalletEndorsingOrgs(ctx contractapi.TransactionContextInterface, walletID string) ([]string, error) {
+	policy, err := ctx.GetStub().GetStateValidationParameter(walletID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read endorsement policy: %v", err)
+	}
+	if len(policy) == 0 {
+		return []string{}, nil
+	}
+
+	ep, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endorsement policy: %v", err)
+	}
+	return ep.ListOrgs(), nil
+}
+
+// setWalletEndorsingOrgs replaces the key-level policy of a wallet with one peer of each organization
+func setWalletEndorsingOrgs(ctx contractapi.TransactionContextInterface, walletID string, orgs []string) error {
+	ep, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	if err := ep.AddOrgs(statebased.RoleTypePeer, orgs...); err != nil {
+		return fmt.Errorf("failed to build endorsement policy: %v", err)
+	}
+	policy, err := ep.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to build endorsement policy: %v", err)
+	}
+
+	if err := ctx.GetStub().SetStateValidationParameter(walletID, policy); err != nil {
+		return fmt.Errorf("failed to set endorsement policy: %v", err)
+	}
+	return nil
+}
+
+// changeWalletEndorsingOrgs sets the key-level policy of a wallet on behalf of an admin and emits an event
+func changeWalletEndorsingOrgs(ctx contractapi.TransactionContextInterface, walletID string, orgs []string) error {
+	if err := setWalletEndorsingOrgs(ctx, walletID, orgs); err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"walletId":  walletID,
+		"orgs":      orgs,
+		"timestamp": now.Format(time.RFC3339),
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("WalletEndorsementChanged", eventJSON)
+
+	return nil
+}
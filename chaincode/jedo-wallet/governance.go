@@ -0,0 +1,545 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Proposal kinds of the rulebook hierarchy (Manifest → Law → Ordinance)
+const (
+	kindManifest  = "manifest"
+	kindLaw       = "law"
+	kindOrdinance = "ordinance"
+
+	// Votes on an official, created by StartConfidenceVote and StartNoConfidenceVote only
+	kindConfidence   = "confidence"
+	kindNoConfidence = "no-confidence"
+
+	// Minting for a public-good project, created by CreatePublicGoodProposal only
+	kindPublicGood = "public-good"
+
+	// Retro-ratification of an executed Security Council action, created by the council only
+	kindRatification = "ratification"
+)
+
+// roundQuorums are the approval shares in percent that must be exceeded in round 1, 2 and 3
+var roundQuorums = []int{80, 60, 50}
+
+// Proposal is a governance vote on a Manifest, Law or Ordinance, on the confidence in an official, on a public-good project or on the ratification of a council action
+type Proposal struct {
+	DocType     string          `json:"docType"`
+	ProposalID  string          `json:"proposalId"`
+	Kind        string          `json:"kind"`   // manifest, law, ordinance, confidence, no-confidence, public-good, ratification
+	Level       string          `json:"level"`  // orbis, regnum, ager
+	UnitID      string          `json:"unitId"` // Orbis, Regnum or Ager the vote is held in
+	Title       string          `json:"title"`
+	DocumentCID string          `json:"documentCid"` // IPFS CID of the proposed text
+	ProposerID  string          `json:"proposerId"`
+	VotingDays  int             `json:"votingDays"` // Length of a round, also used as cool-down between rounds
+	Round       int             `json:"round"`      // Current round (1-3)
+	RoundStart  string          `json:"roundStart"`
+	RoundEnd    string          `json:"roundEnd"`
+	Status      string          `json:"status"` // voting, passed, rejected
+	Results     []ProposalRound `json:"results"`
+	CreatedAt   string          `json:"createdAt"`
+	DecidedAt   string          `json:"decidedAt,omitempty"`
+	TxID        string          `json:"txId"`
+
+	// Public-good projects only
+	BeneficiaryWalletID string             `json:"beneficiaryWalletId,omitempty"`
+	Amount              int64              `json:"amount,omitempty"`             // Requested JEDO in minor units
+	PayoutIntervalDays  int                `json:"payoutIntervalDays,omitempty"` // Days between installments
+	Payouts             []PublicGoodPayout `json:"payouts,omitempty"`
+
+	// Ratifications only
+	ActionID string `json:"actionId,omitempty"` // Council action to ratify
+}
+
+// ProposalRound is the on-chain tally of one voting round
+type ProposalRound struct {
+	Round     int             `json:"round"`
+	Quorum    int             `json:"quorum"` // Approval share in percent that had to be exceeded
+	Yes       int             `json:"yes"`    // Human votes in total
+	No        int             `json:"no"`
+	Passed    bool            `json:"passed"` // Combined decision of all chambers
+	Chambers  []ChamberResult `json:"chambers"`
+	Units     []UnitResult    `json:"units"` // Sub-results per Ager and Regnum (Regnum and Orbis votes)
+	TalliedAt string          `json:"talliedAt,omitempty"`
+}
+
+// ProposalVote is the vote of one human in one round
+type ProposalVote struct {
+	DocType    string `json:"docType"`
+	ProposalID string `json:"proposalId"`
+	Round      int    `json:"round"`
+	VoterID    string `json:"voterId"`
+	Choice     string `json:"choice"` // yes, no
+	Timestamp  string `json:"timestamp"`
+	TxID       string `json:"txId"`
+}
+
+// validateProposalKind checks that kind exists at the given level (Manifest only at Orbis, Law and Ordinance at Regnum and Ager)
+func validateProposalKind(kind string, level string) error {
+	switch kind {
+	case kindManifest:
+		if level != levelOrbis {
+			return fmt.Errorf("a manifest can only be proposed at orbis level")
+		}
+	case kindLaw, kindOrdinance:
+		if level != levelRegnum && level != levelAger {
+			return fmt.Errorf("a %s can only be proposed at regnum or ager level", kind)
+		}
+	default:
+		return fmt.Errorf("kind must be %s, %s or %s", kindManifest, kindLaw, kindOrdinance)
+	}
+	return nil
+}
+
+// inVotingScope reports whether a human belongs to the unit a vote is held in
+func inVotingScope(path ownerPath, level string, unitID string) bool {
+	switch level {
+	case levelAger:
+		return path.Ager == unitID
+	case levelRegnum:
+		return path.Regnum == unitID
+	case levelOrbis:
+		return path.Orbis == unitID
+	}
+	return false
+}
+
+// quorumReached reports whether the yes votes exceed quorum percent of all votes cast
+func quorumReached(yes int, no int, quorum int) bool {
+	total := yes + no
+	return total > 0 && yes*100 > quorum*total
+}
+
+// getCallerHuman returns the owner ID and hierarchy path of a calling human
+func getCallerHuman(ctx contractapi.TransactionContextInterface) (string, ownerPath, error) {
+	if !isHuman(ctx) {
+		return "", ownerPath{}, fmt.Errorf("only humans can vote")
+	}
+
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return "", ownerPath{}, err
+	}
+
+	path, err := parseOwnerPath(caller.CN, levelHuman)
+	if err != nil {
+		return "", ownerPath{}, err
+	}
+	if !caller.isOwner(caller.CN, levelHuman) {
+		return "", ownerPath{}, fmt.Errorf("identity %s was not issued by ager %s", caller.CN, path.Ager)
+	}
+	return caller.CN, path, nil
+}
+
+// requireEligibleVoter fails unless the human has the voting right and belongs to the unit of the vote
+func (s *SmartContract) requireEligibleVoter(ctx contractapi.TransactionContextInterface, ownerID string, path ownerPath, level string, unitID string) error {
+	if !inVotingScope(path, level, unitID) {
+		return fmt.Errorf("%s is not a member of %s %s", ownerID, level, unitID)
+	}
+
+	eligibility, err := s.checkVoterEligibility(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if !eligibility.Eligible {
+		return fmt.Errorf("%s is not an eligible voter (failed: %s)", ownerID, strings.Join(eligibility.FailedCriteria, ", "))
+	}
+	return nil
+}
+
+// CreateProposal opens round 1 of a vote on a Manifest, Law or Ordinance (admin or eligible human of the unit)
+func (s *SmartContract) CreateProposal(
+	ctx contractapi.TransactionContextInterface,
+	proposalID string,
+	kind string,
+	level string,
+	unitID string,
+	title string,
+	documentCID string,
+	votingDays int,
+) (*Proposal, error) {
+	if err := validateLevel(level); err != nil {
+		return nil, err
+	}
+	if err := validateProposalKind(kind, level); err != nil {
+		return nil, err
+	}
+	if err := validateUnitID(level, unitID); err != nil {
+		return nil, err
+	}
+
+	proposerID, err := s.getProposer(ctx, level, unitID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.openProposal(ctx, &Proposal{
+		ProposalID:  proposalID,
+		Kind:        kind,
+		Level:       level,
+		UnitID:      unitID,
+		Title:       title,
+		DocumentCID: documentCID,
+		ProposerID:  proposerID,
+		VotingDays:  votingDays,
+	})
+}
+
+// getProposer returns the ID of a caller allowed to propose in a unit (admin or eligible human of the unit)
+func (s *SmartContract) getProposer(ctx contractapi.TransactionContextInterface, level string, unitID string) (string, error) {
+	if isAdmin(ctx) {
+		return ctx.GetClientIdentity().GetID()
+	}
+
+	ownerID, path, err := getCallerHuman(ctx)
+	if err != nil {
+		return "", fmt.Errorf("only admin or eligible humans can create proposals")
+	}
+	if err := s.requireEligibleVoter(ctx, ownerID, path, level, unitID); err != nil {
+		return "", err
+	}
+	return ownerID, nil
+}
+
+// openProposal stores a new proposal whose round 1 starts now; the caller fills in subject, scope and proposer
+func (s *SmartContract) openProposal(ctx contractapi.TransactionContextInterface, proposal *Proposal) (*Proposal, error) {
+	if err := validateUnitID("proposal", proposal.ProposalID); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(proposal.Title) == "" {
+		return nil, fmt.Errorf("title cannot be empty")
+	}
+	if proposal.VotingDays < 1 || proposal.VotingDays > 365 {
+		return nil, fmt.Errorf("voting days must be between 1 and 365")
+	}
+
+	key, err := ctx.GetStub().CreateCompositeKey("proposal", []string{proposal.ProposalID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("proposal %s already exists", proposal.ProposalID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	proposal.DocType = "proposal"
+	proposal.Round = 1
+	proposal.RoundStart = now.Format(time.RFC3339)
+	proposal.RoundEnd = now.AddDate(0, 0, proposal.VotingDays).Format(time.RFC3339)
+	proposal.Status = "voting"
+	proposal.Results = []ProposalRound{}
+	proposal.CreatedAt = now.Format(time.RFC3339)
+	proposal.TxID = ctx.GetStub().GetTxID()
+
+	if err := putProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"proposalId": proposal.ProposalID,
+		"kind":       proposal.Kind,
+		"level":      proposal.Level,
+		"unitId":     proposal.UnitID,
+		"roundEnd":   proposal.RoundEnd,
+		"timestamp":  proposal.CreatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("ProposalCreated", eventJSON)
+
+	return proposal, nil
+}
+
+// CastVote records the vote of the calling human in the current round (eligible humans of the unit, one vote per round)
+func (s *SmartContract) CastVote(ctx contractapi.TransactionContextInterface, proposalID string, choice string) error {
+	if choice != "yes" && choice != "no" {
+		return fmt.Errorf("choice must be yes or no")
+	}
+
+	voterID, path, err := getCallerHuman(ctx)
+	if err != nil {
+		return err
+	}
+
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return err
+	}
+	if proposal.Status != "voting" {
+		return fmt.Errorf("proposal %s is %s", proposalID, proposal.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkRoundOpen(proposal.RoundStart, proposal.RoundEnd, now); err != nil {
+		return fmt.Errorf("round %d of proposal %s %v", proposal.Round, proposalID, err)
+	}
+
+	if err := s.requireEligibleVoter(ctx, voterID, path, proposal.Level, proposal.UnitID); err != nil {
+		return err
+	}
+
+	// One human, one vote per round
+	voteKey, err := ctx.GetStub().CreateCompositeKey("proposalVote", []string{proposalID, strconv.Itoa(proposal.Round), voterID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(voteKey)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("%s has already voted in round %d of proposal %s", voterID, proposal.Round, proposalID)
+	}
+
+	vote := ProposalVote{
+		DocType:    "proposalVote",
+		ProposalID: proposalID,
+		Round:      proposal.Round,
+		VoterID:    voterID,
+		Choice:     choice,
+		Timestamp:  now.Format(time.RFC3339),
+		TxID:       ctx.GetStub().GetTxID(),
+	}
+
+	voteJSON, err := json.Marshal(vote)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(voteKey, voteJSON)
+}
+
+// TallyProposal counts the votes of the current round in all chambers once it has ended (public, deterministic).
+// A missed quorum opens the next round after a cool-down of the voting duration; after round 3 the proposal is rejected.
+func (s *SmartContract) TallyProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Status != "voting" {
+		return nil, fmt.Errorf("proposal %s is already %s", proposalID, proposal.Status)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roundEnd, err := time.Parse(time.RFC3339, proposal.RoundEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid round end: %v", err)
+	}
+	if now.Before(roundEnd) {
+		return nil, fmt.Errorf("round %d of proposal %s ends at %s", proposal.Round, proposalID, proposal.RoundEnd)
+	}
+
+	result, err := tallyRound(ctx, proposal, proposal.Round)
+	if err != nil {
+		return nil, err
+	}
+	result.TalliedAt = now.Format(time.RFC3339)
+	proposal.Results = append(proposal.Results, *result)
+
+	switch {
+	case result.Passed:
+		proposal.Status = "passed"
+		proposal.DecidedAt = result.TalliedAt
+	case proposal.Round < len(roundQuorums):
+		// Cool-down as long as the voting itself, then the next round with a lower quorum
+		nextStart := roundEnd.AddDate(0, 0, proposal.VotingDays)
+		proposal.Round++
+		proposal.RoundStart = nextStart.Format(time.RFC3339)
+		proposal.RoundEnd = nextStart.AddDate(0, 0, proposal.VotingDays).Format(time.RFC3339)
+	default:
+		proposal.Status = "rejected"
+		proposal.DecidedAt = result.TalliedAt
+	}
+
+	if err := putProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+
+	// Carry out what a decided vote triggers (e.g. a new election)
+	if proposal.Status != "voting" {
+		if err := s.applyProposalDecision(ctx, proposal); err != nil {
+			return nil, err
+		}
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"proposalId": proposalID,
+		"round":      result.Round,
+		"yes":        result.Yes,
+		"no":         result.No,
+		"passed":     result.Passed,
+		"status":     proposal.Status,
+		"timestamp":  result.TalliedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("ProposalTallied", eventJSON)
+
+	return proposal, nil
+}
+
+// applyProposalDecision carries out what a decided proposal triggers
+func (s *SmartContract) applyProposalDecision(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	switch proposal.Kind {
+	case kindConfidence, kindNoConfidence:
+		return s.applyConfidenceDecision(ctx, proposal)
+	case kindPublicGood:
+		return s.applyPublicGoodDecision(ctx, proposal)
+	case kindRatification:
+		return s.applyRatificationDecision(ctx, proposal)
+	}
+	return nil
+}
+
+// GetProposal returns a proposal with its round results (public)
+func (s *SmartContract) GetProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	return getProposal(ctx, proposalID)
+}
+
+// GetProposals returns all proposals, optionally filtered by level, unit and status (public)
+func (s *SmartContract) GetProposals(ctx contractapi.TransactionContextInterface, level string, unitID string, status string) ([]*Proposal, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("proposal", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get proposals: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var proposals []*Proposal
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var proposal Proposal
+		err = json.Unmarshal(queryResponse.Value, &proposal)
+		if err != nil {
+			return nil, err
+		}
+
+		if (level != "" && proposal.Level != level) || (unitID != "" && proposal.UnitID != unitID) || (status != "" && proposal.Status != status) {
+			continue
+		}
+
+		proposals = append(proposals, &proposal)
+	}
+
+	return proposals, nil
+}
+
+// GetProposalVotes returns the votes cast in one round of a proposal (public, for recounts)
+func (s *SmartContract) GetProposalVotes(ctx contractapi.TransactionContextInterface, proposalID string, round int) ([]*ProposalVote, error) {
+	if round < 1 || round > len(roundQuorums) {
+		return nil, fmt.Errorf("round must be between 1 and %d", len(roundQuorums))
+	}
+	return getProposalVotes(ctx, proposalID, round)
+}
+
+// checkRoundOpen fails unless now lies within [start, end)
+func checkRoundOpen(start string, end string, now time.Time) error {
+	startTime, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		return fmt.Errorf("has an invalid start: %v", err)
+	}
+	endTime, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		return fmt.Errorf("has an invalid end: %v", err)
+	}
+	if now.Before(startTime) {
+		return fmt.Errorf("opens at %s (cool-down)", start)
+	}
+	if !now.Before(endTime) {
+		return fmt.Errorf("closed at %s", end)
+	}
+	return nil
+}
+
+// getProposal reads a proposal from the world state
+func getProposal(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("proposal", []string{proposalID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	proposalJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if proposalJSON == nil {
+		return nil, fmt.Errorf("proposal %s does not exist", proposalID)
+	}
+
+	var proposal Proposal
+	if err := json.Unmarshal(proposalJSON, &proposal); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal proposal: %v", err)
+	}
+	return &proposal, nil
+}
+
+// putProposal writes a proposal to the world state
+func putProposal(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	key, err := ctx.GetStub().CreateCompositeKey("proposal", []string{proposal.ProposalID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	proposalJSON, err := json.Marshal(proposal)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(key, proposalJSON); err != nil {
+		return fmt.Errorf("failed to save proposal: %v", err)
+	}
+	return nil
+}
+
+// getProposalVotes reads all votes of one round of a proposal
+func getProposalVotes(ctx contractapi.TransactionContextInterface, proposalID string, round int) ([]*ProposalVote, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("proposalVote", []string{proposalID, strconv.Itoa(round)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get votes: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var votes []*ProposalVote
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var vote ProposalVote
+		err = json.Unmarshal(queryResponse.Value, &vote)
+		if err != nil {
+			return nil, err
+		}
+
+		votes = append(votes, &vote)
+	}
+
+	return votes, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Organizational levels of the JEDO hierarchy (Orbis → Regnum → Ager → Gens → Human)
+const (
+	levelOrbis  = "orbis"
+	levelRegnum = "regnum"
+	levelAger   = "ager"
+	levelGens   = "gens"
+	levelHuman  = "human"
+)
+
+// ownerPath is the position of a wallet owner in the JEDO hierarchy
+type ownerPath struct {
+	Human  string
+	Gens   string
+	Ager   string
+	Regnum string
+	Orbis  string
+}
+
+// parseOwnerPath splits an owner ID such as hans.worb.alps.ea.jedo.cc into its hierarchy labels.
+// Gens owners have no human label; everything after the regnum label is the Orbis domain.
+func parseOwnerPath(ownerID string, ownerType string) (ownerPath, error) {
+	labels := strings.Split(ownerID, ".")
+
+	var path ownerPath
+	switch ownerType {
+	case "", levelHuman:
+		if len(labels) < 6 {
+			return path, fmt.Errorf("owner ID %s is not a human identity", ownerID)
+		}
+		path.Human = labels[0]
+		labels = labels[1:]
+	case levelGens:
+		if len(labels) < 5 {
+			return path, fmt.Errorf("owner ID %s is not a gens identity", ownerID)
+		}
+	default:
+		return path, fmt.Errorf("owner type %s has no hierarchy path", ownerType)
+	}
+
+	path.Gens = labels[0]
+	path.Ager = labels[1]
+	path.Regnum = labels[2]
+	path.Orbis = strings.Join(labels[3:], ".")
+
+	for _, label := range []string{path.Gens, path.Ager, path.Regnum, path.Orbis} {
+		if label == "" {
+			return path, fmt.Errorf("owner ID %s contains an empty label", ownerID)
+		}
+	}
+	return path, nil
+}
+
+// walletAger returns the Ager a wallet belongs to, "" for regnum and orbis treasuries
+func walletAger(wallet *Wallet) string {
+	switch wallet.OwnerType {
+	case levelAger:
+		return wallet.OwnerID
+	case levelRegnum, levelOrbis:
+		return ""
+	}
+	path, err := parseOwnerPath(wallet.OwnerID, wallet.OwnerType)
+	if err != nil {
+		return ""
+	}
+	return path.Ager
+}
+
+// validateLevel checks that level is one of the tax-raising organizational levels
+func validateLevel(level string) error {
+	switch level {
+	case levelAger, levelRegnum, levelOrbis:
+		return nil
+	}
+	return fmt.Errorf("level must be %s, %s or %s", levelAger, levelRegnum, levelOrbis)
+}
@@ -0,0 +1,276 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// transactionFilter restricts a history query; empty fields and zero amounts do not filter
+type transactionFilter struct {
+	From         string   // Inclusive lower bound, RFC3339 UTC
+	Before       string   // Exclusive upper bound, RFC3339 UTC
+	Types        []string // Transaction types, any of them
+	Counterparty string
+	MinAmount    int64 // Absolute amount in minor units, incoming and outgoing alike
+	MaxAmount    int64
+}
+
+// parseTransactionFilter parses the filter arguments of QueryWalletHistory.
+// Dates are RFC3339 timestamps or plain dates (YYYY-MM-DD), a plain toDate includes the whole day.
+func parseTransactionFilter(fromDate string, toDate string, txType string, counterparty string, minAmount string, maxAmount string) (*transactionFilter, error) {
+	filter := &transactionFilter{Counterparty: counterparty}
+	if txType != "" {
+		filter.Types = []string{txType}
+	}
+
+	if fromDate != "" {
+		from, _, err := parseHistoryDate(fromDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid from date: %v", err)
+		}
+		filter.From = from.Format(time.RFC3339)
+	}
+	if toDate != "" {
+		to, dateOnly, err := parseHistoryDate(toDate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid to date: %v", err)
+		}
+		if dateOnly {
+			to = to.AddDate(0, 0, 1)
+		} else {
+			to = to.Add(time.Second)
+		}
+		filter.Before = to.Format(time.RFC3339)
+	}
+	if filter.From != "" && filter.Before != "" && filter.From >= filter.Before {
+		return nil, fmt.Errorf("from date must be before to date")
+	}
+
+	var err error
+	if minAmount != "" {
+		if filter.MinAmount, err = parseAmount(minAmount); err != nil {
+			return nil, fmt.Errorf("invalid minimum amount: %v", err)
+		}
+	}
+	if maxAmount != "" {
+		if filter.MaxAmount, err = parseAmount(maxAmount); err != nil {
+			return nil, fmt.Errorf("invalid maximum amount: %v", err)
+		}
+		if filter.MaxAmount > 0 && filter.MaxAmount < filter.MinAmount {
+			return nil, fmt.Errorf("maximum amount must not be below minimum amount")
+		}
+	}
+
+	return filter, nil
+}
+
+// parseHistoryDate parses an RFC3339 timestamp or a plain date and reports which one it was
+func parseHistoryDate(value string) (time.Time, bool, error) {
+	if date, err := time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+	timestamp, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%s is neither a date (YYYY-MM-DD) nor an RFC3339 timestamp", value)
+	}
+	return timestamp.UTC(), false, nil
+}
+
+// transactionQuery returns the CouchDB query for the records of a wallet matching filter, newest first
+func transactionQuery(walletID string, filter *transactionFilter) (string, error) {
+	query := newCouchQuery("transaction").eq("walletId", walletID)
+
+	// A timestamp condition is needed for CouchDB to sort on the index, even without a date range
+	if filter.From != "" {
+		query.op("timestamp", "$gte", filter.From)
+	} else {
+		query.op("timestamp", "$gt", "")
+	}
+	if filter.Before != "" {
+		query.op("timestamp", "$lt", filter.Before)
+	}
+
+	if len(filter.Types) == 1 {
+		query.eq("type", filter.Types[0])
+	} else if len(filter.Types) > 1 {
+		types := make([]interface{}, len(filter.Types))
+		for i, txType := range filter.Types {
+			types[i] = txType
+		}
+		query.in("type", types...)
+	}
+	if filter.Counterparty != "" {
+		query.eq("counterparty", filter.Counterparty)
+	}
+
+	// Outgoing records carry negative amounts, so the range applies to both signs
+	if filter.MinAmount > 0 || filter.MaxAmount > 0 {
+		incoming := make(map[string]interface{})
+		outgoing := make(map[string]interface{})
+		if filter.MinAmount > 0 {
+			incoming["$gte"] = filter.MinAmount
+			outgoing["$lte"] = -filter.MinAmount
+		} else {
+			incoming["$gt"] = 0
+			outgoing["$lt"] = 0
+		}
+		if filter.MaxAmount > 0 {
+			incoming["$lte"] = filter.MaxAmount
+			outgoing["$gte"] = -filter.MaxAmount
+		}
+		query.or(
+			map[string]interface{}{"amount": incoming},
+			map[string]interface{}{"amount": outgoing},
+		)
+	}
+
+	return query.
+		sortBy("docType", true).
+		sortBy("walletId", true).
+		sortBy("timestamp", true).
+		useIndex("indexWalletTimestampDoc", "indexWalletTimestamp").
+		build()
+}
+
+// QueryWalletHistory returns a page of the transaction records of a wallet, newest first (only owner or admin).
+// fromDate/toDate limit the period, txType and counterparty must match exactly,
+// minAmount/maxAmount limit the absolute amount; empty arguments do not filter.
+func (s *SmartContract) QueryWalletHistory(
+	ctx contractapi.TransactionContextInterface,
+	walletID string,
+	fromDate string,
+	toDate string,
+	txType string,
+	counterparty string,
+	minAmount string,
+	maxAmount string,
+	pageSize int32,
+	bookmark string,
+) (*TransactionPage, error) {
+	wallet, err := s.authorizeWalletHistory(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	filter, err := parseTransactionFilter(fromDate, toDate, txType, counterparty, minAmount, maxAmount)
+	if err != nil {
+		return nil, err
+	}
+	queryString, err := transactionQuery(walletID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	nextBookmark, fetched := pageMetadata(metadata)
+	page := &TransactionPage{Transactions: []*Transaction{}, PageSize: pageSize, Bookmark: nextBookmark, FetchedCount: fetched}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var tx Transaction
+		err = json.Unmarshal(queryResponse.Value, &tx)
+		if err != nil {
+			return nil, err
+		}
+		mergeTransactionPrivateDetails(ctx, wallet, &tx, queryResponse.Key)
+
+		page.Transactions = append(page.Transactions, &tx)
+	}
+
+	return page, nil
+}
+
+// getWalletTransactions returns the records of a wallet matching filter, newest first; limit 0 = unlimited
+// (internal, no access control)
+func getWalletTransactions(ctx contractapi.TransactionContextInterface, walletID string, filter *transactionFilter, limit int) ([]*Transaction, error) {
+	queryString, err := transactionQuery(walletID, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query transaction history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var transactions []*Transaction
+	for resultsIterator.HasNext() && (limit == 0 || len(transactions) < limit) {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var tx Transaction
+		err = json.Unmarshal(queryResponse.Value, &tx)
+		if err != nil {
+			return nil, err
+		}
+
+		transactions = append(transactions, &tx)
+	}
+
+	return transactions, nil
+}
+
+// GetWalletStateHistory returns every committed version of a wallet document, newest first (only owner or admin).
+// Each version carries the committing transaction, its block timestamp and UpdatedBy of the writer;
+// in private data mode the versions hold the metadata hash only.
+func (s *SmartContract) GetWalletStateHistory(ctx contractapi.TransactionContextInterface, walletID string) ([]*HistoryQueryResult, error) {
+	if _, err := s.authorizeWalletHistory(ctx, walletID); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetHistoryForKey(walletID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallet history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	history := []*HistoryQueryResult{}
+	for resultsIterator.HasNext() {
+		modification, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		// A delete has no document, the record then only carries the wallet ID (schema validation rejects null)
+		record := &Wallet{WalletID: walletID}
+		if !modification.IsDelete && len(modification.Value) > 0 {
+			if err := json.Unmarshal(modification.Value, record); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal wallet version %s: %v", modification.TxId, err)
+			}
+		}
+		if record.Metadata == nil {
+			record.Metadata = make(map[string]string)
+		}
+
+		var timestamp string
+		if modification.Timestamp != nil {
+			timestamp = modification.Timestamp.AsTime().UTC().Format(time.RFC3339)
+		}
+
+		history = append(history, &HistoryQueryResult{
+			Record:    record,
+			TxID:      modification.TxId,
+			Timestamp: timestamp,
+			IsDelete:  modification.IsDelete,
+		})
+	}
+
+	return history, nil
+}
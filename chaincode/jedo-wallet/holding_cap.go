@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// HoldingCap defines the maximum JEDO an owner may hold across all wallets
+type HoldingCap struct {
+	DocType   string `json:"docType"`
+	HumanCap  int64  `json:"humanCap"` // Cap per human in minor units (0 = no cap)
+	GensCap   int64  `json:"gensCap"`  // Cap per gens in minor units (0 = no cap)
+	UpdatedBy string `json:"updatedBy"`
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// holdingCapKey returns the state key of the holding cap configuration
+func holdingCapKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("config", []string{"holdingCap"})
+}
+
+// SetHoldingCap sets the channel-wide maximum holding per human and per gens (Orbis admin only, "0" disables a cap)
+func (s *SmartContract) SetHoldingCap(ctx contractapi.TransactionContextInterface, humanCap string, gensCap string) error {
+	// Admin check, the cap applies to every Ager
+	if !isOrbisAdmin(ctx) {
+		return fmt.Errorf("only an Orbis admin can set the holding cap")
+	}
+
+	humanMinor, err := parseAmount(humanCap)
+	if err != nil {
+		return fmt.Errorf("invalid human cap: %v", err)
+	}
+	gensMinor, err := parseAmount(gensCap)
+	if err != nil {
+		return fmt.Errorf("invalid gens cap: %v", err)
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	holdingCap := HoldingCap{
+		DocType:   "holdingCap",
+		HumanCap:  humanMinor,
+		GensCap:   gensMinor,
+		UpdatedBy: callerID,
+		UpdatedAt: now.Format(time.RFC3339),
+	}
+
+	capJSON, err := json.Marshal(holdingCap)
+	if err != nil {
+		return err
+	}
+
+	key, err := holdingCapKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, capJSON); err != nil {
+		return fmt.Errorf("failed to save holding cap: %v", err)
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"humanCap":  formatAmount(humanMinor),
+		"gensCap":   formatAmount(gensMinor),
+		"timestamp": holdingCap.UpdatedAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("HoldingCapUpdated", eventJSON)
+
+	return nil
+}
+
+// GetHoldingCap returns the configured holding caps (public, so wallets can warn before paying)
+func (s *SmartContract) GetHoldingCap(ctx contractapi.TransactionContextInterface) (*HoldingCap, error) {
+	key, err := holdingCapKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	capJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read holding cap: %v", err)
+	}
+	if capJSON == nil {
+		// No cap configured yet
+		return &HoldingCap{DocType: "holdingCap"}, nil
+	}
+
+	var holdingCap HoldingCap
+	if err := json.Unmarshal(capJSON, &holdingCap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal holding cap: %v", err)
+	}
+
+	return &holdingCap, nil
+}
+
+// CheckHoldingCap reports whether a wallet can receive an amount without its owner exceeding the cap
+// (only the owner of the wallet or an admin, so nobody can probe the holdings of others)
+func (s *SmartContract) CheckHoldingCap(ctx contractapi.TransactionContextInterface, walletID string, amountStr string) (bool, error) {
+	amount, err := parseAmount(amountStr)
+	if err != nil {
+		return false, err
+	}
+
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return false, err
+	}
+
+	// Only wallet owner or admin can check
+	if !isAdmin(ctx) && !caller.isOwner(wallet.OwnerID, wallet.OwnerType) {
+		return false, fmt.Errorf("you can only check the holding cap of your own wallet")
+	}
+
+	exceeds, _, err := s.exceedsHoldingCap(ctx, wallet, amount)
+	if err != nil {
+		return false, err
+	}
+	return !exceeds, nil
+}
+
+// checkHoldingCap fails if crediting amount to wallet would push its owner above the holding cap.
+// wallet.Balance is taken as is, so repeated credits to the same wallet within one transaction add up.
+func (s *SmartContract) checkHoldingCap(ctx contractapi.TransactionContextInterface, wallet *Wallet, amount int64) error {
+	exceeds, limit, err := s.exceedsHoldingCap(ctx, wallet, amount)
+	if err != nil {
+		return err
+	}
+	if exceeds {
+		return fmt.Errorf("wallet %s cannot receive %s: owner would exceed the holding cap of %s", wallet.WalletID, formatAmount(amount), formatAmount(limit))
+	}
+	return nil
+}
+
+// exceedsHoldingCap reports whether crediting amount to wallet would push its owner above the cap, and the cap
+func (s *SmartContract) exceedsHoldingCap(ctx contractapi.TransactionContextInterface, wallet *Wallet, amount int64) (bool, int64, error) {
+	holdingCap, err := s.GetHoldingCap(ctx)
+	if err != nil {
+		return false, 0, err
+	}
+
+	// Only humans and gens are capped; treasury wallets of ager, regnum and orbis are not
+	var limit int64
+	switch wallet.OwnerType {
+	case "", levelHuman:
+		limit = holdingCap.HumanCap
+	case levelGens:
+		limit = holdingCap.GensCap
+	}
+	if limit == 0 {
+		return false, 0, nil
+	}
+
+	holdings, err := getOwnerHoldings(ctx, wallet.OwnerID, wallet.WalletID)
+	if err != nil {
+		return false, 0, err
+	}
+	if holdings, err = addAmounts(holdings, wallet.Balance); err != nil {
+		return false, 0, err
+	}
+	if holdings, err = addAmounts(holdings, amount); err != nil {
+		return false, 0, err
+	}
+
+	return holdings > limit, limit, nil
+}
+
+// getOwnerHoldings sums the committed balances of all wallets of an owner, except excludeWalletID.
+// The wallets are read by key (see getOwnerWallets), so a concurrent change of any of them invalidates the transaction.
+func getOwnerHoldings(ctx contractapi.TransactionContextInterface, ownerID string, excludeWalletID string) (int64, error) {
+	wallets, err := getOwnerWallets(ctx, ownerID)
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for _, wallet := range wallets {
+		if wallet.WalletID == excludeWalletID {
+			continue
+		}
+
+		if total, err = addAmounts(total, wallet.Balance); err != nil {
+			return 0, err
+		}
+	}
+
+	return total, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// setHoldingCap makes alps the Orbis admin MSP and sets the caps
+func (l *testLedger) setHoldingCap(humanCap string, gensCap string) {
+	l.t.Helper()
+	l.must(l.admin(func(ctx contractapi.TransactionContextInterface) error {
+		config, err := getOrbisConfig(ctx)
+		if err != nil || len(config.AdminMSPs) > 0 {
+			return err
+		}
+		return l.s.SetOrbisConfig(ctx, "jedo.dev", `["alps"]`)
+	}))
+	l.must(l.admin(func(ctx contractapi.TransactionContextInterface) error {
+		return l.s.SetHoldingCap(ctx, humanCap, gensCap)
+	}))
+}
+
+func TestExceedsHoldingCap(t *testing.T) {
+	l := newTestLedger(t)
+	l.putWallet(&Wallet{WalletID: "hans-1", OwnerID: "hans.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Balance: 400})
+	l.putWallet(&Wallet{WalletID: "hans-2", OwnerID: "hans.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Balance: 300})
+	l.putWallet(&Wallet{WalletID: "vreni-1", OwnerID: "vreni.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Balance: 300})
+	l.putWallet(&Wallet{WalletID: "worb-1", OwnerID: "worb.alps.ea.jedo.dev", OwnerType: levelGens, Balance: 400})
+	l.putWallet(&Wallet{WalletID: "alps-treasury", OwnerID: "alps.ea.jedo.dev", OwnerType: levelAger, Balance: 1000000})
+
+	tests := []struct {
+		name       string
+		humanCap   string
+		gensCap    string
+		walletID   string
+		amount     int64
+		wantExceed bool
+	}{
+		{"all wallets of the owner count", "10", "5", "hans-1", 300, false},
+		{"one minor unit above the cap", "10", "5", "hans-1", 301, true},
+		{"credit to another wallet of the owner", "10", "5", "hans-2", 301, true},
+		{"other owners do not count", "10", "5", "vreni-1", 700, false},
+		{"other owner above the cap", "10", "5", "vreni-1", 701, true},
+		{"gens cap", "10", "5", "worb-1", 100, false},
+		{"gens above its cap", "10", "5", "worb-1", 101, true},
+		{"treasuries are not capped", "10", "5", "alps-treasury", 1000000, false},
+		{"zero disables the human cap", "0", "5", "hans-1", 1000000, false},
+		{"zero disables the gens cap", "10", "0", "worb-1", 1000000, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l.setHoldingCap(tt.humanCap, tt.gensCap)
+			l.must(l.admin(func(ctx contractapi.TransactionContextInterface) error {
+				wallet, err := l.s.GetWallet(ctx, tt.walletID)
+				if err != nil {
+					return err
+				}
+				exceeds, _, err := l.s.exceedsHoldingCap(ctx, wallet, tt.amount)
+				if err != nil {
+					return err
+				}
+				if exceeds != tt.wantExceed {
+					t.Errorf("exceedsHoldingCap(%s, %s) = %v, want %v", tt.walletID, formatAmount(tt.amount), exceeds, tt.wantExceed)
+				}
+				return nil
+			}))
+		})
+	}
+}
+
+func TestCheckHoldingCapPendingCredit(t *testing.T) {
+	l := newTestLedger(t)
+	l.putWallet(&Wallet{WalletID: "hans-1", OwnerID: "hans.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Balance: 400})
+	l.putWallet(&Wallet{WalletID: "hans-2", OwnerID: "hans.worb.alps.ea.jedo.dev", OwnerType: levelHuman, Balance: 300})
+	l.setHoldingCap("10", "0")
+
+	// A credit already applied to the in-memory wallet within the same transaction counts
+	l.must(l.admin(func(ctx contractapi.TransactionContextInterface) error {
+		wallet, err := l.s.GetWallet(ctx, "hans-1")
+		if err != nil {
+			return err
+		}
+		wallet.Balance += 250
+		if err := l.s.checkHoldingCap(ctx, wallet, 50); err != nil {
+			t.Errorf("checkHoldingCap at the cap: %v", err)
+		}
+		if err := l.s.checkHoldingCap(ctx, wallet, 51); err == nil {
+			t.Errorf("checkHoldingCap above the cap succeeded")
+		}
+		return nil
+	}))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// clientIdentity is the parsed subject of an X.509 client certificate.
+// Field meaning follows docs/infrastructure/certificate-subject-convention.md.
+// Only CN (the enrollment ID), OU (NodeOUs, affiliation) and MSPID are set by the registrar;
+// O, L, ST and C come from the enrollee's CSR and must never grant anything.
+type clientIdentity struct {
+	MSPID    string   // MSP of the issuing CA, the Ager name
+	CN       string   // e.g. nik.worb.alps.ea.jedo.dev
+	OU       []string // Fabric NodeOUs and CA affiliations, e.g. client, admin
+	O        string   // Ager, e.g. alps
+	L        string   // Regnum, e.g. ea
+	ST       string   // Environment: dev, test or prod
+	C        string
+	IssuerCN string
+}
+
+// getCallerIdentity parses the identity of the caller
+func getCallerIdentity(ctx contractapi.TransactionContextInterface) (*clientIdentity, error) {
+	rawID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client identity: %v", err)
+	}
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client MSP ID: %v", err)
+	}
+
+	id, err := parseClientID(rawID)
+	if err != nil {
+		return nil, err
+	}
+	id.MSPID = mspID
+	return id, nil
+}
+
+// parseClientID parses a GetID() value, base64("x509::<subject DN>::<issuer DN>")
+func parseClientID(rawID string) (*clientIdentity, error) {
+	decoded, err := base64.StdEncoding.DecodeString(rawID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to base64-decode client identity: %v", err)
+	}
+
+	parts := strings.SplitN(string(decoded), "::", 3)
+	if len(parts) != 3 || parts[0] != "x509" {
+		return nil, fmt.Errorf("unexpected client identity format: %s", decoded)
+	}
+
+	subject, err := parseDistinguishedName(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid subject: %v", err)
+	}
+	issuer, err := parseDistinguishedName(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid issuer: %v", err)
+	}
+
+	id := &clientIdentity{
+		CN:       firstValue(subject, "CN"),
+		OU:       subject["OU"],
+		O:        firstValue(subject, "O"),
+		L:        firstValue(subject, "L"),
+		ST:       firstValue(subject, "ST"),
+		C:        firstValue(subject, "C"),
+		IssuerCN: firstValue(issuer, "CN"),
+	}
+	if id.CN == "" {
+		return nil, fmt.Errorf("client identity has no common name")
+	}
+	return id, nil
+}
+
+// parseDistinguishedName splits an RFC 2253 distinguished name (as produced by Go's pkix.Name.String)
+// into its attributes, honouring backslash escapes
+func parseDistinguishedName(dn string) (map[string][]string, error) {
+	attributes := make(map[string][]string)
+
+	var current strings.Builder
+	var components []string
+	escaped := false
+	for _, c := range dn {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == ',' || c == '+':
+			components = append(components, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(c)
+		}
+	}
+	if escaped {
+		return nil, fmt.Errorf("dangling escape in %q", dn)
+	}
+	components = append(components, current.String())
+
+	for _, component := range components {
+		component = strings.TrimSpace(component)
+		if component == "" {
+			continue
+		}
+		key, value, found := strings.Cut(component, "=")
+		if !found {
+			return nil, fmt.Errorf("malformed attribute %q", component)
+		}
+		key = strings.ToUpper(strings.TrimSpace(key))
+		attributes[key] = append(attributes[key], value)
+	}
+	return attributes, nil
+}
+
+// firstValue returns the first value of a DN attribute, or "" if it is missing
+func firstValue(attributes map[string][]string, key string) string {
+	if values := attributes[key]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// isOwner reports whether the identity is exactly the given owner: same CN, issued by the MSP of the owner's Ager.
+// Without the MSP check any Ager's CA could enroll a CN of another Ager and act as its owner.
+func (id *clientIdentity) isOwner(ownerID string, ownerType string) bool {
+	if ownerID == "" || id.CN != ownerID {
+		return false
+	}
+	path, err := parseOwnerPath(ownerID, ownerType)
+	return err == nil && path.Ager == id.MSPID
+}
+
+// isGensOf reports whether the identity is the gens directly above the human ownerID
+func (id *clientIdentity) isGensOf(ownerID string) bool {
+	return isDirectChild(ownerID, id.CN) && id.isOwner(id.CN, levelGens)
+}
+
+// hasOU reports whether the identity carries the organizational unit
+func (id *clientIdentity) hasOU(ou string) bool {
+	for _, value := range id.OU {
+		if value == ou {
+			return true
+		}
+	}
+	return false
+}
+
+// firstLabel returns the leftmost label of the common name (the human or gens name)
+func (id *clientIdentity) firstLabel() string {
+	label, _, _ := strings.Cut(id.CN, ".")
+	return label
+}
+
+// isDirectChild reports whether childID is exactly one label below parentID,
+// e.g. hans.worb.alps.ea.jedo.cc below worb.alps.ea.jedo.cc
+func isDirectChild(childID string, parentID string) bool {
+	label, rest, found := strings.Cut(childID, ".")
+	return found && label != "" && parentID != "" && rest == parentID
+}
+
+// unitPrefix returns the CN labels in front of <MSP ID>.<regnum>.<orbis domain>
+// (e.g. [hans worb] for hans.worb.alps.ea.jedo.dev from MSP alps in Orbis jedo.dev);
+// nil if the CN does not sit below the caller's own Ager in that Orbis
+func (id *clientIdentity) unitPrefix(orbisDomain string) []string {
+	if id.MSPID == "" || orbisDomain == "" {
+		return nil
+	}
+	rest, found := strings.CutSuffix(id.CN, "."+orbisDomain)
+	if !found {
+		return nil
+	}
+	labels := strings.Split(rest, ".")
+	if len(labels) < 3 || labels[len(labels)-2] != id.MSPID || labels[len(labels)-1] == "" {
+		return nil
+	}
+	return labels[:len(labels)-2]
+}
@@ -0,0 +1,876 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TaxArrears is the unpaid per-capita tax of a blocked human
+type TaxArrears struct {
+	DocType          string           `json:"docType"`
+	OwnerID          string           `json:"ownerId"`
+	AgerID           string           `json:"agerId"`
+	TreasuryWalletID string           `json:"treasuryWalletId"` // Ager treasury receiving the arrears
+	Amount           int64            `json:"amount"`           // Outstanding amount in minor units
+	Periods          []string         `json:"periods"`          // Tax periods with a shortfall
+	PeriodAmounts    map[string]int64 `json:"periodAmounts"`    // Outstanding amount per period, paid oldest first
+	UpdatedAt        string           `json:"updatedAt"`
+}
+
+// outstanding returns what is still owed for a period
+func (a *TaxArrears) outstanding(period string) int64 {
+	if a.PeriodAmounts == nil {
+		// Arrears recorded before per-period amounts
+		return a.Amount
+	}
+	return a.PeriodAmounts[period]
+}
+
+// reduce removes a paid or covered amount from the arrears, from the given period or, if empty, oldest period first
+func (a *TaxArrears) reduce(amount int64, period string) {
+	a.Amount -= amount
+	if a.PeriodAmounts == nil {
+		return
+	}
+
+	periods := a.Periods
+	if period != "" {
+		periods = []string{period}
+	}
+	for _, p := range periods {
+		if amount == 0 {
+			break
+		}
+		part := a.PeriodAmounts[p]
+		if part > amount {
+			part = amount
+		}
+		a.PeriodAmounts[p] -= part
+		amount -= part
+		if a.PeriodAmounts[p] == 0 {
+			delete(a.PeriodAmounts, p)
+		}
+	}
+}
+
+// ShortfallShare is the part of a shortfall charged to one remaining payer
+type ShortfallShare struct {
+	PayerID string `json:"payerId"`
+	Due     int64  `json:"due"`
+	Paid    int64  `json:"paid"`
+}
+
+// ShortfallRedistribution records how the shortfall of one defaulter was spread
+type ShortfallRedistribution struct {
+	Level       string            `json:"level"`       // Collecting level (ager, regnum or orbis)
+	DefaulterID string            `json:"defaulterId"` // Human, ager or regnum that could not pay
+	PayeeID     string            `json:"payeeId"`     // Unit the tax is owed to
+	Shortfall   int64             `json:"shortfall"`   // Part of the period's shortfall still outstanding, spread across the shares
+	Repaid      int64             `json:"repaid"`      // Part a defaulting human had already paid as arrears
+	Shares      []*ShortfallShare `json:"shares,omitempty"`
+	Uncovered   int64             `json:"uncovered"` // Part the remaining payers could not cover either
+}
+
+// RedistributionReport is the result of one redistribution call: a phase of one report page of an Ager's
+// settlement (see TaxRedistribution), or the levies of a period (AgerID empty)
+type RedistributionReport struct {
+	DocType         string                     `json:"docType"`
+	Period          string                     `json:"period"`
+	AgerID          string                     `json:"agerId,omitempty"`
+	Phase           string                     `json:"phase,omitempty"`
+	Page            int                        `json:"page"` // Report page of SettleTaxes processed
+	Step            int                        `json:"step"` // Number of the call for the Ager, from 0
+	Redistributions []*ShortfallRedistribution `json:"redistributions"`
+	Shares          []*ShortfallShare          `json:"shares"` // Humans charged in phase shares
+	TotalShortfall  int64                      `json:"totalShortfall"`
+	TotalCovered    int64                      `json:"totalCovered"`
+	TotalUncovered  int64                      `json:"totalUncovered"`
+	Complete        bool                       `json:"complete"` // false if RedistributeTaxShortfalls must be called again
+	RunBy           string                     `json:"runBy"`
+	TxID            string                     `json:"txId"`
+	Timestamp       string                     `json:"timestamp"`
+}
+
+// Phases of a TaxRedistribution
+const (
+	redistributionShortfalls = "shortfalls"
+	redistributionShares     = "shares"
+	redistributionArrears    = "arrears"
+	redistributionComplete   = "complete"
+)
+
+// TaxRedistribution tracks the solidarity redistribution of one Ager and period across calls. Each call processes
+// one report page of SettleTaxes: phase shortfalls sums what the defaulters still owe for the period, phase shares
+// charges it equally to the humans who paid in full, phase arrears removes the covered part from the arrears of
+// the defaulters in report order. While it runs the arrears of the period cannot be paid, so the sum stays valid.
+type TaxRedistribution struct {
+	DocType   string `json:"docType"`
+	Period    string `json:"period"`
+	AgerID    string `json:"agerId"`
+	Phase     string `json:"phase"`
+	Page      int    `json:"page"`  // Next report page of the phase
+	Steps     int    `json:"steps"` // Reports written so far (see GetTaxRedistributionReport)
+	Shortfall int64  `json:"shortfall"`
+	Payers    int    `json:"payers"`    // Humans who paid in full
+	Charged   int    `json:"charged"`   // Payers charged so far, the first Shortfall % Payers pay one minor unit more
+	Covered   int64  `json:"covered"`   // Paid by the payers
+	Allocated int64  `json:"allocated"` // Part of Covered already removed from arrears
+	UpdatedAt string `json:"updatedAt"`
+}
+
+// taxArrearsKey returns the state key of the arrears of a human
+func taxArrearsKey(ctx contractapi.TransactionContextInterface, ownerID string) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("taxArrears", []string{ownerID})
+}
+
+// getTaxArrears reads the arrears of a human, or nil if there are none
+func getTaxArrears(ctx contractapi.TransactionContextInterface, ownerID string) (*TaxArrears, error) {
+	key, err := taxArrearsKey(ctx, ownerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	arrearsJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if arrearsJSON == nil {
+		return nil, nil
+	}
+
+	var arrears TaxArrears
+	if err := json.Unmarshal(arrearsJSON, &arrears); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal tax arrears: %v", err)
+	}
+	return &arrears, nil
+}
+
+// addTaxArrears adds an unpaid amount of a period to the arrears of a human
+func addTaxArrears(ctx contractapi.TransactionContextInterface, ownerID string, agerID string, treasuryWalletID string, amount int64, period string) error {
+	arrears, err := getTaxArrears(ctx, ownerID)
+	if err != nil {
+		return err
+	}
+	if arrears == nil {
+		arrears = &TaxArrears{DocType: "taxArrears", OwnerID: ownerID, Periods: []string{}, PeriodAmounts: map[string]int64{}}
+	}
+
+	arrears.AgerID = agerID
+	arrears.TreasuryWalletID = treasuryWalletID
+	if arrears.Amount, err = addAmounts(arrears.Amount, amount); err != nil {
+		return err
+	}
+	arrears.Periods = append(arrears.Periods, period)
+	if arrears.PeriodAmounts != nil {
+		arrears.PeriodAmounts[period] += amount
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	arrears.UpdatedAt = now.Format(time.RFC3339)
+
+	return putTaxArrears(ctx, arrears)
+}
+
+// putTaxArrears writes the arrears of a human, or deletes them once fully paid
+func putTaxArrears(ctx contractapi.TransactionContextInterface, arrears *TaxArrears) error {
+	key, err := taxArrearsKey(ctx, arrears.OwnerID)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	if arrears.Amount == 0 {
+		return ctx.GetStub().DelState(key)
+	}
+
+	arrearsJSON, err := json.Marshal(arrears)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, arrearsJSON)
+}
+
+// GetTaxArrears returns the outstanding taxes of a human (only that human or admin)
+func (s *SmartContract) GetTaxArrears(ctx contractapi.TransactionContextInterface, ownerID string) (*TaxArrears, error) {
+	callerRole, err := getCallerRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if callerRole != "admin" && !caller.isOwner(ownerID, levelHuman) {
+		return nil, fmt.Errorf("you can only view your own tax arrears")
+	}
+
+	arrears, err := getTaxArrears(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+	if arrears == nil {
+		return nil, fmt.Errorf("no tax arrears for %s", ownerID)
+	}
+	return arrears, nil
+}
+
+// PayTaxArrears pays outstanding taxes from a wallet, even if it is blocked (only owner or admin).
+// Once the arrears are fully paid, all blocked wallets of the owner are unblocked automatically.
+func (s *SmartContract) PayTaxArrears(ctx contractapi.TransactionContextInterface, walletID string) error {
+	callerRole, err := getCallerRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return err
+	}
+
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return err
+	}
+
+	if callerRole != "admin" && !caller.isOwner(wallet.OwnerID, wallet.OwnerType) {
+		return fmt.Errorf("you can only pay arrears from your own wallet")
+	}
+
+	if wallet.Status != "active" && wallet.Status != "blocked" {
+		return fmt.Errorf("wallet %s is not active (status: %s)", walletID, wallet.Status)
+	}
+
+	arrears, err := getTaxArrears(ctx, wallet.OwnerID)
+	if err != nil {
+		return err
+	}
+	if arrears == nil {
+		return fmt.Errorf("no tax arrears for %s", wallet.OwnerID)
+	}
+
+	// A running redistribution has summed the arrears of its period, they stay as they are until it is complete
+	for _, period := range arrears.Periods {
+		redistribution, err := getTaxRedistribution(ctx, period, arrears.AgerID)
+		if err != nil {
+			return err
+		}
+		if redistribution != nil && redistribution.Phase != redistributionComplete {
+			return fmt.Errorf("the shortfalls of period %s are being redistributed, pay the arrears once that is complete", period)
+		}
+	}
+
+	amount := arrears.Amount
+	if wallet.Balance < amount {
+		amount = wallet.Balance
+	}
+	if amount <= 0 {
+		return fmt.Errorf("insufficient balance: wallet %s has %s", walletID, formatAmount(wallet.Balance))
+	}
+
+	treasury, err := s.GetWallet(ctx, arrears.TreasuryWalletID)
+	if err != nil {
+		return fmt.Errorf("treasury wallet error: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	now := txTime.Format(time.RFC3339)
+	txID := ctx.GetStub().GetTxID()
+
+	wallet.Balance -= amount
+	wallet.UpdatedAt = now
+	if treasury.Balance, err = addAmounts(treasury.Balance, amount); err != nil {
+		return err
+	}
+	treasury.UpdatedAt = now
+
+	arrears.reduce(amount, "")
+	arrears.UpdatedAt = now
+	if err := putTaxArrears(ctx, arrears); err != nil {
+		return err
+	}
+
+	// Unblock all wallets of the owner once nothing is outstanding
+	unblocked := []string{}
+	if arrears.Amount == 0 {
+		ownerWallets, err := getOwnerWallets(ctx, wallet.OwnerID)
+		if err != nil {
+			return err
+		}
+		for _, ownerWallet := range ownerWallets {
+			if ownerWallet.WalletID == walletID {
+				ownerWallet = wallet
+			}
+			if ownerWallet.Status != "blocked" {
+				continue
+			}
+
+			ownerWallet.Status = "active"
+			ownerWallet.UpdatedAt = now
+			unblocked = append(unblocked, ownerWallet.WalletID)
+
+			if ownerWallet.WalletID != walletID {
+				if err := putWalletState(ctx, ownerWallet); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := putWalletState(ctx, wallet); err != nil {
+		return err
+	}
+	if err := putWalletState(ctx, treasury); err != nil {
+		return err
+	}
+
+	// Record transactions
+	payTx := Transaction{
+		TxID:         txID,
+		WalletID:     walletID,
+		Type:         "tax_arrears",
+		Amount:       -amount,
+		Balance:      wallet.Balance,
+		Counterparty: treasury.WalletID,
+		Description:  "Tax arrears",
+		Timestamp:    now,
+	}
+	if err := putTransaction(ctx, wallet, &payTx); err != nil {
+		return err
+	}
+
+	receiveTx := Transaction{
+		TxID:         txID,
+		WalletID:     treasury.WalletID,
+		Type:         "tax_arrears_in",
+		Amount:       amount,
+		Balance:      treasury.Balance,
+		Counterparty: walletID,
+		Description:  "Tax arrears",
+		Timestamp:    now,
+	}
+	if err := putTransaction(ctx, treasury, &receiveTx); err != nil {
+		return err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"ownerId":     wallet.OwnerID,
+		"walletId":    walletID,
+		"amount":      formatAmount(amount),
+		"outstanding": formatAmount(arrears.Amount),
+		"unblocked":   unblocked,
+		"timestamp":   now,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("TaxArrearsPaid", eventJSON)
+
+	return nil
+}
+
+// taxRedistributionKey returns the state key of the redistribution of an Ager in a period
+func taxRedistributionKey(ctx contractapi.TransactionContextInterface, period string, agerID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("taxRedistribution", []string{period, agerID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// taxRedistributionReportKey returns the state key of the report of one redistribution call of an Ager
+func taxRedistributionReportKey(ctx contractapi.TransactionContextInterface, period string, agerID string, step int) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("taxRedistributionReport", []string{period, agerID, fmt.Sprintf("%06d", step)})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// levyRedistributionKey returns the state key of the levy redistribution report of a period
+func levyRedistributionKey(ctx contractapi.TransactionContextInterface, period string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("levyRedistribution", []string{period})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// getTaxRedistribution reads the redistribution of an Ager in a period, nil if it has not started
+func getTaxRedistribution(ctx contractapi.TransactionContextInterface, period string, agerID string) (*TaxRedistribution, error) {
+	key, err := taxRedistributionKey(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	var redistribution TaxRedistribution
+	found, err := getTaxDocument(ctx, key, &redistribution)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &redistribution, nil
+}
+
+// outstandingShortfall returns the arrears of a defaulting human and what is still owed of the entry's shortfall
+func outstandingShortfall(ctx contractapi.TransactionContextInterface, entry *TaxEntry, period string) (*TaxArrears, int64, error) {
+	arrears, err := getTaxArrears(ctx, entry.PayerID)
+	if err != nil || arrears == nil {
+		return nil, 0, err
+	}
+	outstanding := arrears.outstanding(period)
+	if outstanding > entry.Shortfall {
+		outstanding = entry.Shortfall
+	}
+	return arrears, outstanding, nil
+}
+
+// RedistributeTaxShortfalls spreads the unpaid per-capita taxes of an Ager's settled period equally across the
+// humans of the Ager who paid in full (admin of that Ager only). Each call processes one report page of SettleTaxes in the current
+// phase (see TaxRedistribution); call it again until the report is complete. Only the arrears still outstanding
+// for the period are spread and the covered part is removed from them, so the treasury is not paid twice;
+// a human whose arrears are cleared is unblocked.
+func (s *SmartContract) RedistributeTaxShortfalls(ctx contractapi.TransactionContextInterface, period string, agerID string) (*RedistributionReport, error) {
+	// Admin check, an admin of another Ager's MSP must not charge this Ager's humans
+	if !isAgerAdmin(ctx, agerID) {
+		return nil, fmt.Errorf("only an admin of ager %s can redistribute its tax shortfalls", agerID)
+	}
+
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+	if err := validateUnitID(levelAger, agerID); err != nil {
+		return nil, err
+	}
+
+	settlement, err := getAgerTaxSettlement(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement == nil || !settlement.Complete {
+		return nil, fmt.Errorf("taxes of ager %s for period %s are not settled completely", agerID, period)
+	}
+
+	progress, err := getTaxRedistribution(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if progress == nil {
+		progress = &TaxRedistribution{DocType: "taxRedistribution", Period: period, AgerID: agerID, Phase: redistributionShortfalls, Payers: settlement.FullPayers}
+	} else if progress.Phase == redistributionComplete {
+		return nil, fmt.Errorf("shortfalls of ager %s for period %s have already been redistributed", agerID, period)
+	}
+
+	page, err := s.GetTaxReport(ctx, period, agerID, progress.Page)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RedistributionReport{
+		DocType:         "taxRedistributionReport",
+		Period:          period,
+		AgerID:          agerID,
+		Phase:           progress.Phase,
+		Page:            progress.Page,
+		Step:            progress.Steps,
+		Redistributions: []*ShortfallRedistribution{},
+		Shares:          []*ShortfallShare{},
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	timestamp := now.Format(time.RFC3339)
+	t := newTaxSettlement(period)
+
+	switch progress.Phase {
+	case redistributionShortfalls:
+		for _, entry := range page.Entries {
+			if entry.Shortfall == 0 {
+				continue
+			}
+			_, outstanding, err := outstandingShortfall(ctx, entry, period)
+			if err != nil {
+				return nil, err
+			}
+			if outstanding == 0 {
+				continue
+			}
+
+			report.Redistributions = append(report.Redistributions, &ShortfallRedistribution{
+				Level:       levelAger,
+				DefaulterID: entry.PayerID,
+				PayeeID:     agerID,
+				Shortfall:   outstanding,
+				Repaid:      entry.Shortfall - outstanding,
+				Uncovered:   outstanding,
+			})
+			progress.Shortfall += outstanding
+			report.TotalShortfall += outstanding
+		}
+
+	case redistributionShares:
+		config, err := getTaxConfig(ctx, levelAger, agerID)
+		if err != nil {
+			return nil, err
+		}
+		if config == nil {
+			return nil, fmt.Errorf("no tax config for ager %s", agerID)
+		}
+		treasury, err := s.loadTreasury(ctx, t, config)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range page.Entries {
+			if entry.Shortfall != 0 {
+				continue
+			}
+			share := &ShortfallShare{PayerID: entry.PayerID, Due: progress.Shortfall / int64(progress.Payers)}
+			if int64(progress.Charged) < progress.Shortfall%int64(progress.Payers) {
+				share.Due++
+			}
+			progress.Charged++
+			if share.Due == 0 {
+				continue
+			}
+
+			wallets, err := getOwnerWallets(ctx, entry.PayerID)
+			if err != nil {
+				return nil, err
+			}
+			t.addHuman(entry.PayerID, wallets)
+			share.Paid = t.collect(t.humanWallets[entry.PayerID], treasury, share.Due, "solidarity", "solidarity_in")
+
+			report.Shares = append(report.Shares, share)
+			progress.Covered += share.Paid
+			report.TotalCovered += share.Paid
+		}
+
+	case redistributionArrears:
+		for _, entry := range page.Entries {
+			if entry.Shortfall == 0 {
+				continue
+			}
+			arrears, outstanding, err := outstandingShortfall(ctx, entry, period)
+			if err != nil {
+				return nil, err
+			}
+			if outstanding == 0 {
+				continue
+			}
+
+			// The payers now carry the covered part, the human no longer owes it
+			covered := progress.Covered - progress.Allocated
+			if covered > outstanding {
+				covered = outstanding
+			}
+			if covered > 0 {
+				arrears.reduce(covered, period)
+				arrears.UpdatedAt = timestamp
+				if err := putTaxArrears(ctx, arrears); err != nil {
+					return nil, err
+				}
+				if arrears.Amount == 0 {
+					wallets, err := getOwnerWallets(ctx, entry.PayerID)
+					if err != nil {
+						return nil, err
+					}
+					t.addHuman(entry.PayerID, wallets)
+					t.unblock(entry.PayerID)
+				}
+				progress.Allocated += covered
+			}
+
+			report.Redistributions = append(report.Redistributions, &ShortfallRedistribution{
+				Level:       levelAger,
+				DefaulterID: entry.PayerID,
+				PayeeID:     agerID,
+				Shortfall:   outstanding,
+				Repaid:      entry.Shortfall - outstanding,
+				Uncovered:   outstanding - covered,
+			})
+			report.TotalShortfall += outstanding
+			report.TotalCovered += covered
+			report.TotalUncovered += outstanding - covered
+		}
+	}
+
+	// Next page, or next phase once all pages are done; a phase with nothing to do is skipped
+	progress.Page++
+	if progress.Page >= settlement.Pages {
+		progress.Page = 0
+		switch {
+		case progress.Phase == redistributionShortfalls && progress.Shortfall > 0 && progress.Payers > 0:
+			progress.Phase = redistributionShares
+		case progress.Phase == redistributionShares && progress.Covered > 0:
+			progress.Phase = redistributionArrears
+		default:
+			progress.Phase = redistributionComplete
+		}
+	}
+	progress.Steps++
+	progress.UpdatedAt = timestamp
+	report.Complete = progress.Phase == redistributionComplete
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, err
+	}
+	report.RunBy = callerID
+	report.TxID = ctx.GetStub().GetTxID()
+	report.Timestamp = timestamp
+
+	if err := t.commit(ctx, report.TxID, report.Timestamp); err != nil {
+		return nil, err
+	}
+
+	reportKey, err := taxRedistributionReportKey(ctx, period, agerID, report.Step)
+	if err != nil {
+		return nil, err
+	}
+	if err := putTaxDocument(ctx, reportKey, report); err != nil {
+		return nil, err
+	}
+	progressKey, err := taxRedistributionKey(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := putTaxDocument(ctx, progressKey, progress); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"period":         period,
+		"agerId":         agerID,
+		"phase":          report.Phase,
+		"page":           report.Page,
+		"complete":       report.Complete,
+		"totalShortfall": formatAmount(report.TotalShortfall),
+		"totalCovered":   formatAmount(report.TotalCovered),
+		"totalUncovered": formatAmount(report.TotalUncovered),
+		"timestamp":      report.Timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("TaxShortfallsRedistributed", eventJSON)
+
+	return report, nil
+}
+
+// RedistributeLevyShortfalls spreads the unpaid levies of a period across the remaining payers of the same level
+// (Orbis admin only): ager shortfalls across the other agers of the regnum and regnum shortfalls across the other regnums.
+// Only treasury wallets are touched, so it runs in one transaction.
+func (s *SmartContract) RedistributeLevyShortfalls(ctx contractapi.TransactionContextInterface, period string) (*RedistributionReport, error) {
+	// Admin check, the levies charge the treasuries of all Agers and Regnums
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can redistribute levy shortfalls")
+	}
+
+	levies, err := s.GetTaxLevies(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+
+	reportKey, err := levyRedistributionKey(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	var existing RedistributionReport
+	found, err := getTaxDocument(ctx, reportKey, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, fmt.Errorf("levy shortfalls of period %s have already been redistributed", period)
+	}
+
+	configs, err := s.loadTaxConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t := newTaxSettlement(period)
+
+	// Treasury a payer of a given level pays from
+	payerWallets := func(level string, payerID string) ([]*Wallet, error) {
+		subLevel := levelAger
+		if level == levelOrbis {
+			subLevel = levelRegnum
+		}
+		config := configs[subLevel][payerID]
+		if config == nil {
+			return nil, nil
+		}
+		wallet, err := s.loadWallet(ctx, t, config.TreasuryWalletID)
+		if err != nil {
+			return nil, err
+		}
+		return []*Wallet{wallet}, nil
+	}
+
+	// Group the levy entries by collecting unit
+	groups := make(map[string][]*TaxEntry)
+	var groupKeys []string
+	for _, entry := range levies.Entries {
+		key := entry.Level + "~" + entry.PayeeID
+		if _, known := groups[key]; !known {
+			groupKeys = append(groupKeys, key)
+		}
+		groups[key] = append(groups[key], entry)
+	}
+	sort.Strings(groupKeys)
+
+	report := &RedistributionReport{
+		DocType:         "levyRedistribution",
+		Period:          period,
+		Redistributions: []*ShortfallRedistribution{},
+		Shares:          []*ShortfallShare{},
+		Complete:        true,
+	}
+
+	for _, key := range groupKeys {
+		entries := groups[key]
+		level, payeeID := entries[0].Level, entries[0].PayeeID
+
+		config := configs[level][payeeID]
+		if config == nil {
+			return nil, fmt.Errorf("no tax config for %s %s", level, payeeID)
+		}
+		treasury, err := s.loadTreasury(ctx, t, config)
+		if err != nil {
+			return nil, err
+		}
+
+		// Only payers who paid in full share the shortfall
+		var remaining []string
+		for _, entry := range entries {
+			if entry.Shortfall == 0 {
+				remaining = append(remaining, entry.PayerID)
+			}
+		}
+
+		for _, entry := range entries {
+			if entry.Shortfall == 0 {
+				continue
+			}
+
+			redistribution := &ShortfallRedistribution{
+				Level:       level,
+				DefaulterID: entry.PayerID,
+				PayeeID:     payeeID,
+				Shortfall:   entry.Shortfall,
+				Shares:      []*ShortfallShare{},
+				Uncovered:   entry.Shortfall,
+			}
+
+			if len(remaining) > 0 {
+				// Without head counts splitLevy splits equally
+				shares, err := splitLevy(entry.Shortfall, remaining, nil)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, payerID := range remaining {
+					wallets, err := payerWallets(level, payerID)
+					if err != nil {
+						return nil, err
+					}
+					share := &ShortfallShare{PayerID: payerID, Due: shares[payerID]}
+					share.Paid = t.collect(wallets, treasury, share.Due, "solidarity", "solidarity_in")
+					redistribution.Shares = append(redistribution.Shares, share)
+					redistribution.Uncovered -= share.Paid
+				}
+			}
+
+			report.Redistributions = append(report.Redistributions, redistribution)
+			report.TotalShortfall += redistribution.Shortfall
+			report.TotalCovered += redistribution.Shortfall - redistribution.Uncovered
+			report.TotalUncovered += redistribution.Uncovered
+		}
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.RunBy = callerID
+	report.TxID = ctx.GetStub().GetTxID()
+	report.Timestamp = now.Format(time.RFC3339)
+
+	if err := t.commit(ctx, report.TxID, report.Timestamp); err != nil {
+		return nil, err
+	}
+	if err := putTaxDocument(ctx, reportKey, report); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"period":         period,
+		"totalShortfall": formatAmount(report.TotalShortfall),
+		"totalCovered":   formatAmount(report.TotalCovered),
+		"totalUncovered": formatAmount(report.TotalUncovered),
+		"timestamp":      report.Timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("LevyShortfallsRedistributed", eventJSON)
+
+	return report, nil
+}
+
+// GetTaxRedistribution returns the progress and totals of the redistribution of an Ager in a period (public)
+func (s *SmartContract) GetTaxRedistribution(ctx contractapi.TransactionContextInterface, period string, agerID string) (*TaxRedistribution, error) {
+	redistribution, err := getTaxRedistribution(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if redistribution == nil {
+		return nil, fmt.Errorf("no redistribution of ager %s for period %s", agerID, period)
+	}
+	return redistribution, nil
+}
+
+// GetTaxRedistributionReport returns the report of one redistribution call of an Ager (public, for audits)
+func (s *SmartContract) GetTaxRedistributionReport(ctx contractapi.TransactionContextInterface, period string, agerID string, step int) (*RedistributionReport, error) {
+	key, err := taxRedistributionReportKey(ctx, period, agerID, step)
+	if err != nil {
+		return nil, err
+	}
+	var report RedistributionReport
+	found, err := getTaxDocument(ctx, key, &report)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no redistribution report %d of ager %s for period %s", step, agerID, period)
+	}
+	return &report, nil
+}
+
+// GetLevyRedistribution returns the levy redistribution report of a period (public)
+func (s *SmartContract) GetLevyRedistribution(ctx contractapi.TransactionContextInterface, period string) (*RedistributionReport, error) {
+	key, err := levyRedistributionKey(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	var report RedistributionReport
+	found, err := getTaxDocument(ctx, key, &report)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no levy redistribution report for period %s", period)
+	}
+	return &report, nil
+}
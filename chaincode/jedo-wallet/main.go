@@ -8,7 +8,10 @@ import (
 )
 
 func main() {
-	walletChaincode, err := contractapi.NewChaincode(&SmartContract{})
+	contract := &SmartContract{}
+	contract.BeforeTransaction = checkFunctionNotPaused
+
+	walletChaincode, err := contractapi.NewChaincode(contract)
 	if err != nil {
 		log.Panicf("Error creating jedo-wallet chaincode: %v", err)
 	}
@@ -0,0 +1,832 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// currentSchemaVersion is the version of wallet and transaction documents written by this chaincode.
+// Documents without a version (version 0) still carry float64 amounts and must be migrated.
+const currentSchemaVersion = 2
+
+// AmountMigrationReport summarizes one MigrateAmounts run
+type AmountMigrationReport struct {
+	WalletsMigrated      int               `json:"walletsMigrated"`
+	TransactionsMigrated int               `json:"transactionsMigrated"`
+	LegacyTotal          string            `json:"legacyTotal"`   // Sum of the migrated wallet balances before conversion
+	MigratedTotal        string            `json:"migratedTotal"` // Sum of the migrated wallet balances after conversion
+	RoundingTotal        string            `json:"roundingTotal"` // MigratedTotal minus LegacyTotal
+	Roundings            []*AmountRounding `json:"roundings"`     // Amounts that were not exact in minor units
+	Complete             bool              `json:"complete"`      // false if the limit was reached and another run is needed
+	Timestamp            string            `json:"timestamp"`
+}
+
+// AmountRounding records one legacy amount that had to be rounded to minor units, e.g. float drift like 0.30000000000000004
+type AmountRounding struct {
+	WalletID string `json:"walletId"`
+	TxID     string `json:"txId,omitempty"` // Set for transaction records
+	Field    string `json:"field"`          // balance or amount
+	Legacy   string `json:"legacy"`         // Stored float64 literal
+	Migrated string `json:"migrated"`       // Rounded amount
+	Delta    string `json:"delta"`          // Migrated minus legacy, exact
+}
+
+// roundLegacyAmount converts a float64 JSON literal to minor units, rounding half away from zero.
+// It returns the difference of the rounded amount to the literal.
+func roundLegacyAmount(literal json.Number) (int64, *big.Rat, error) {
+	legacy, ok := new(big.Rat).SetString(literal.String())
+	if !ok {
+		return 0, nil, fmt.Errorf("invalid legacy amount %s", literal)
+	}
+
+	scaled := new(big.Rat).Mul(legacy, new(big.Rat).SetInt64(amountScale))
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(remainder), big.NewInt(2)).Cmp(scaled.Denom()) >= 0 {
+		quotient.Add(quotient, big.NewInt(int64(scaled.Num().Sign())))
+	}
+	if !quotient.IsInt64() {
+		return 0, nil, fmt.Errorf("legacy amount %s is out of range", literal)
+	}
+
+	minor := quotient.Int64()
+	delta := new(big.Rat).Sub(new(big.Rat).SetFrac64(minor, amountScale), legacy)
+	return minor, delta, nil
+}
+
+// exactDecimal formats a rational with as many decimals as it needs (float64 literals are finite decimals)
+func exactDecimal(value *big.Rat) string {
+	for decimals := amountDecimals; ; decimals++ {
+		formatted := value.FloatString(decimals)
+		if parsed, ok := new(big.Rat).SetString(formatted); (ok && parsed.Cmp(value) == 0) || decimals >= 400 {
+			return formatted
+		}
+	}
+}
+
+// migrateLegacyAmount rounds a legacy amount and records a rounding in the report
+func (r *AmountMigrationReport) migrateLegacyAmount(walletID string, txID string, field string, literal json.Number) (int64, error) {
+	minor, delta, err := roundLegacyAmount(literal)
+	if err != nil {
+		if txID != "" {
+			return 0, fmt.Errorf("transaction %s %s: %v", txID, field, err)
+		}
+		return 0, fmt.Errorf("wallet %s %s: %v", walletID, field, err)
+	}
+	if delta.Sign() != 0 {
+		r.Roundings = append(r.Roundings, &AmountRounding{
+			WalletID: walletID,
+			TxID:     txID,
+			Field:    field,
+			Legacy:   literal.String(),
+			Migrated: formatAmount(minor),
+			Delta:    exactDecimal(delta),
+		})
+	}
+	return minor, nil
+}
+
+// legacyWallet reads a version 0 wallet, keeping the float64 balance as its exact JSON literal
+type legacyWallet struct {
+	Wallet
+	Balance json.Number `json:"balance"`
+}
+
+// legacyTransaction reads a version 0 transaction, keeping the float64 amounts as their exact JSON literals
+type legacyTransaction struct {
+	Transaction
+	Amount  json.Number `json:"amount"`
+	Balance json.Number `json:"balance"`
+}
+
+// schemaVersionOf returns the schemaVersion field of a stored document
+func schemaVersionOf(docJSON []byte) (int, error) {
+	var doc struct {
+		SchemaVersion int `json:"schemaVersion"`
+	}
+	if err := json.Unmarshal(docJSON, &doc); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal document: %v", err)
+	}
+	return doc.SchemaVersion, nil
+}
+
+// checkSchemaVersion rejects documents that have not been migrated to minor-unit amounts yet
+func checkSchemaVersion(docJSON []byte) error {
+	version, err := schemaVersionOf(docJSON)
+	if err != nil {
+		return err
+	}
+	if version < currentSchemaVersion {
+		return fmt.Errorf("document uses the legacy amount format (schema version %d), run MigrateAmounts first", version)
+	}
+	return nil
+}
+
+// MigrateAmounts converts legacy float64 wallet and transaction documents to minor units (Orbis admin only).
+// At most limit documents are converted per call (0 = unlimited). Amounts that drifted off the minor-unit grid
+// are rounded to the nearest minor unit and every rounding is listed in the report.
+func (s *SmartContract) MigrateAmounts(ctx contractapi.TransactionContextInterface, limit int) (*AmountMigrationReport, error) {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can migrate amounts")
+	}
+
+	report := &AmountMigrationReport{Complete: true, Roundings: []*AmountRounding{}}
+	legacyTotal := new(big.Rat)
+	var migratedTotal int64
+
+	// Migrate wallets
+	queryString := `{
+		"selector": {
+			"docType": "wallet",
+			"schemaVersion": {
+				"$exists": false
+			}
+		}
+	}`
+
+	walletIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query legacy wallets: %v", err)
+	}
+	defer walletIterator.Close()
+
+	for walletIterator.HasNext() {
+		if limit > 0 && report.WalletsMigrated+report.TransactionsMigrated >= limit {
+			report.Complete = false
+			break
+		}
+
+		queryResponse, err := walletIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var legacy legacyWallet
+		if err := json.Unmarshal(queryResponse.Value, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal legacy wallet %s: %v", queryResponse.Key, err)
+		}
+
+		balance, err := report.migrateLegacyAmount(legacy.WalletID, "", "balance", legacy.Balance)
+		if err != nil {
+			return nil, err
+		}
+
+		legacyBalance, ok := new(big.Rat).SetString(legacy.Balance.String())
+		if !ok {
+			return nil, fmt.Errorf("wallet %s: invalid legacy balance %s", queryResponse.Key, legacy.Balance)
+		}
+		legacyTotal.Add(legacyTotal, legacyBalance)
+		migratedTotal, err = addAmounts(migratedTotal, balance)
+		if err != nil {
+			return nil, err
+		}
+
+		wallet := legacy.Wallet
+		wallet.Balance = balance
+		wallet.SchemaVersion = currentSchemaVersion
+		if wallet.Metadata == nil {
+			wallet.Metadata = make(map[string]string)
+		}
+
+		walletJSON, err := json.Marshal(wallet)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().PutState(queryResponse.Key, walletJSON); err != nil {
+			return nil, fmt.Errorf("failed to update wallet %s: %v", queryResponse.Key, err)
+		}
+
+		report.WalletsMigrated++
+	}
+
+	// Migrate transaction records
+	txIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("transaction", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transactions: %v", err)
+	}
+	defer txIterator.Close()
+
+	for report.Complete && txIterator.HasNext() {
+		queryResponse, err := txIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		version, err := schemaVersionOf(queryResponse.Value)
+		if err != nil {
+			return nil, err
+		}
+		if version >= currentSchemaVersion {
+			continue
+		}
+
+		if limit > 0 && report.WalletsMigrated+report.TransactionsMigrated >= limit {
+			report.Complete = false
+			break
+		}
+
+		var legacy legacyTransaction
+		if err := json.Unmarshal(queryResponse.Value, &legacy); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal legacy transaction: %v", err)
+		}
+
+		tx := legacy.Transaction
+		if tx.Amount, err = report.migrateLegacyAmount(tx.WalletID, tx.TxID, "amount", legacy.Amount); err != nil {
+			return nil, err
+		}
+		if tx.Balance, err = report.migrateLegacyAmount(tx.WalletID, tx.TxID, "balance", legacy.Balance); err != nil {
+			return nil, err
+		}
+		tx.SchemaVersion = currentSchemaVersion
+
+		txJSON, err := json.Marshal(tx)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().PutState(queryResponse.Key, txJSON); err != nil {
+			return nil, fmt.Errorf("failed to update transaction %s: %v", tx.TxID, err)
+		}
+
+		report.TransactionsMigrated++
+	}
+
+	report.LegacyTotal = exactDecimal(legacyTotal)
+	report.MigratedTotal = formatAmount(migratedTotal)
+	report.RoundingTotal = exactDecimal(new(big.Rat).Sub(new(big.Rat).SetFrac64(migratedTotal, amountScale), legacyTotal))
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Timestamp = now.Format(time.RFC3339)
+
+	eventJSON, _ := json.Marshal(report)
+	_ = ctx.GetStub().SetEvent("AmountsMigrated", eventJSON)
+
+	return report, nil
+}
+
+// GensIDMigrationReport summarizes one MigrateGensIDs run
+type GensIDMigrationReport struct {
+	WalletsUpdated int    `json:"walletsUpdated"`
+	WalletsSkipped int    `json:"walletsSkipped"` // Wallets without a human or gens owner (treasuries)
+	Complete       bool   `json:"complete"`       // false if the limit was reached and another run is needed
+	Timestamp      string `json:"timestamp"`
+}
+
+// MigrateGensIDs stores the gensId on human and gens wallets created before it was indexed (Orbis admin only).
+// At most limit wallets are updated per call (0 = unlimited).
+func (s *SmartContract) MigrateGensIDs(ctx contractapi.TransactionContextInterface, limit int) (*GensIDMigrationReport, error) {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can migrate wallets")
+	}
+
+	queryString, err := newCouchQuery("wallet").exists("gensId", false).build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	report := &GensIDMigrationReport{Complete: true}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkSchemaVersion(queryResponse.Value); err != nil {
+			return nil, fmt.Errorf("wallet %s: %v", queryResponse.Key, err)
+		}
+
+		var wallet Wallet
+		if err := json.Unmarshal(queryResponse.Value, &wallet); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wallet %s: %v", queryResponse.Key, err)
+		}
+
+		path, err := parseOwnerPath(wallet.OwnerID, wallet.OwnerType)
+		if err != nil {
+			report.WalletsSkipped++
+			continue
+		}
+
+		if limit > 0 && report.WalletsUpdated >= limit {
+			report.Complete = false
+			break
+		}
+
+		wallet.GensID = path.Gens
+		if err := putWalletState(ctx, &wallet); err != nil {
+			return nil, err
+		}
+		report.WalletsUpdated++
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Timestamp = now.Format(time.RFC3339)
+
+	eventJSON, _ := json.Marshal(report)
+	_ = ctx.GetStub().SetEvent("GensIDsMigrated", eventJSON)
+
+	return report, nil
+}
+
+// OwnerWalletsMigrationReport summarizes one MigrateOwnerWallets run
+type OwnerWalletsMigrationReport struct {
+	WalletsListed int    `json:"walletsListed"` // Wallets added to the list of their owner
+	WalletsRead   int    `json:"walletsRead"`
+	Cursor        string `json:"cursor"`   // Pass to the next call
+	Complete      bool   `json:"complete"` // false if the limit was reached and another run is needed
+	Timestamp     string `json:"timestamp"`
+}
+
+// MigrateOwnerWallets lists the wallets created before the per-owner wallet lists existed (Orbis admin only).
+// Until it is complete the holding cap, the eligibility checks and SettleTaxes do not see those wallets. At most limit wallets
+// are read per call (0 = unlimited), in walletId order after cursor (empty to start); running it again is harmless.
+func (s *SmartContract) MigrateOwnerWallets(ctx contractapi.TransactionContextInterface, cursor string, limit int) (*OwnerWalletsMigrationReport, error) {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can migrate wallets")
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	query := newCouchQuery("wallet").sortBy("docType", false).sortBy("walletId", false).useIndex("indexWalletDoc", "indexWallet")
+	if cursor != "" {
+		query.op("walletId", "$gt", cursor)
+	}
+	queryString, err := query.build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	report := &OwnerWalletsMigrationReport{Complete: true, Cursor: cursor}
+	var owners []string
+	walletsByOwner := make(map[string][]*Wallet)
+	for resultsIterator.HasNext() {
+		if limit > 0 && report.WalletsRead >= limit {
+			report.Complete = false
+			break
+		}
+
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var wallet Wallet
+		if err := json.Unmarshal(queryResponse.Value, &wallet); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wallet %s: %v", queryResponse.Key, err)
+		}
+
+		if _, found := walletsByOwner[wallet.OwnerID]; !found {
+			owners = append(owners, wallet.OwnerID)
+		}
+		walletsByOwner[wallet.OwnerID] = append(walletsByOwner[wallet.OwnerID], &wallet)
+		report.Cursor = wallet.WalletID
+		report.WalletsRead++
+	}
+
+	// Each list is written once, GetState does not see earlier writes of the same transaction
+	for _, ownerID := range owners {
+		added, err := addOwnerWallets(ctx, walletsByOwner[ownerID]...)
+		if err != nil {
+			return nil, err
+		}
+		report.WalletsListed += added
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Timestamp = now.Format(time.RFC3339)
+
+	eventJSON, _ := json.Marshal(report)
+	_ = ctx.GetStub().SetEvent("OwnerWalletsMigrated", eventJSON)
+
+	return report, nil
+}
+
+// OwnerActivityMigrationReport summarizes one MigrateOwnerActivity run
+type OwnerActivityMigrationReport struct {
+	AgerID    string `json:"agerId"`
+	Owners    int    `json:"owners"`    // Humans whose activity was recounted
+	Transfers int    `json:"transfers"` // Transfers counted for them
+	Cursor    string `json:"cursor"`    // Pass to the next call
+	Complete  bool   `json:"complete"`  // false if the limit was reached and another run is needed
+	Timestamp string `json:"timestamp"`
+}
+
+// MigrateOwnerActivity recounts the transfer activity of the humans of an Ager from their transaction history (Orbis admin only),
+// for transfers made before the activity was kept per owner. Transfers between wallets of the same owner are left out.
+// At most limit humans are recounted per call (0 = unlimited), in ownerId order after cursor (empty to start);
+// it needs the wallet lists of MigrateOwnerWallets and running it again is harmless.
+func (s *SmartContract) MigrateOwnerActivity(ctx contractapi.TransactionContextInterface, agerID string, cursor string, limit int) (*OwnerActivityMigrationReport, error) {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can migrate activity")
+	}
+	if err := validateUnitID(levelAger, agerID); err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	query := newCouchQuery("ownerWallets").eq("agerId", agerID).eq("ownerType", levelHuman).
+		sortBy("docType", false).sortBy("agerId", false).sortBy("ownerType", false).sortBy("ownerId", false).
+		useIndex("indexOwnerWalletsAgerDoc", "indexOwnerWalletsAger")
+	if cursor != "" {
+		query.op("ownerId", "$gt", cursor)
+	}
+	queryString, err := query.build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query humans: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &OwnerActivityMigrationReport{AgerID: agerID, Complete: true, Cursor: cursor}
+	transferTypes := &transactionFilter{Types: []string{"transfer_in", "transfer_out"}}
+	for resultsIterator.HasNext() {
+		if limit > 0 && report.Owners >= limit {
+			report.Complete = false
+			break
+		}
+
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var list OwnerWallets
+		if err := json.Unmarshal(queryResponse.Value, &list); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wallets of %s: %v", queryResponse.Key, err)
+		}
+
+		own := make(map[string]bool)
+		for _, walletID := range list.WalletIDs {
+			own[walletID] = true
+		}
+
+		// A transfer has one record per wallet and batch recipient, each transaction counts once
+		transfers := make(map[string]string)
+		for _, walletID := range list.WalletIDs {
+			transactions, err := getWalletTransactions(ctx, walletID, transferTypes, 0)
+			if err != nil {
+				return nil, err
+			}
+			for _, tx := range transactions {
+				if own[tx.Counterparty] {
+					continue
+				}
+				timestamp, err := time.Parse(time.RFC3339, tx.Timestamp)
+				if err != nil {
+					return nil, fmt.Errorf("transaction %s of wallet %s has an invalid timestamp: %v", tx.TxID, walletID, err)
+				}
+				transfers[tx.TxID] = timestamp.UTC().Format(activityMonthFormat)
+			}
+		}
+
+		// Read the current activity, a transfer committed meanwhile then invalidates this transaction
+		activity, err := getOwnerActivity(ctx, list.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		activity.Transfers = make(map[string]int)
+		for _, month := range transfers {
+			activity.Transfers[month]++
+		}
+		activity.UpdatedAt = now.Format(time.RFC3339)
+		if err := putOwnerActivity(ctx, activity); err != nil {
+			return nil, err
+		}
+
+		report.Owners++
+		report.Transfers += len(transfers)
+		report.Cursor = list.OwnerID
+	}
+
+	report.Timestamp = now.Format(time.RFC3339)
+
+	eventJSON, _ := json.Marshal(report)
+	_ = ctx.GetStub().SetEvent("OwnerActivityMigrated", eventJSON)
+
+	return report, nil
+}
+
+// MemberUnitsMigrationReport summarizes one MigrateMemberUnits run
+type MemberUnitsMigrationReport struct {
+	Memberships int    `json:"memberships"` // Memberships read
+	UnitsAdded  int    `json:"unitsAdded"`  // Agers newly recorded as member units
+	Cursor      string `json:"cursor"`      // Pass to the next call
+	Complete    bool   `json:"complete"`    // false if the limit was reached and another run is needed
+	Timestamp   string `json:"timestamp"`
+}
+
+// MigrateMemberUnits records the member units of the memberships made before tallies read them (Orbis admin only).
+// Until it is complete a Regnum or Orbis vote misses the Agers whose members all joined earlier. At most limit memberships
+// are read per call (0 = unlimited), in ownerId order after cursor (empty to start); running it again is harmless.
+func (s *SmartContract) MigrateMemberUnits(ctx contractapi.TransactionContextInterface, cursor string, limit int) (*MemberUnitsMigrationReport, error) {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can migrate member units")
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	query := newCouchQuery("membership").sortBy("docType", false).sortBy("ownerId", false).
+		useIndex("indexOwnerDoc", "indexOwner")
+	if cursor != "" {
+		query.op("ownerId", "$gt", cursor)
+	}
+	queryString, err := query.build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query memberships: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	report := &MemberUnitsMigrationReport{Complete: true, Cursor: cursor}
+	// GetState does not see the units written by this call, each one is checked once
+	checked := make(map[string]bool)
+	for resultsIterator.HasNext() {
+		if limit > 0 && report.Memberships >= limit {
+			report.Complete = false
+			break
+		}
+
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var membership Membership
+		if err := json.Unmarshal(queryResponse.Value, &membership); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal membership %s: %v", queryResponse.Key, err)
+		}
+		report.Memberships++
+		report.Cursor = membership.OwnerID
+
+		path, err := parseOwnerPath(membership.OwnerID, levelHuman)
+		if err != nil {
+			continue
+		}
+		key := path.Regnum + "/" + path.Ager
+		if checked[key] {
+			continue
+		}
+		checked[key] = true
+
+		unitKey, err := memberUnitKey(ctx, path.Regnum, path.Ager)
+		if err != nil {
+			return nil, err
+		}
+		existing, err := ctx.GetStub().GetState(unitKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read member unit: %v", err)
+		}
+		if existing != nil {
+			continue
+		}
+		if err := addMemberUnit(ctx, path); err != nil {
+			return nil, err
+		}
+		report.UnitsAdded++
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Timestamp = now.Format(time.RFC3339)
+
+	eventJSON, _ := json.Marshal(report)
+	_ = ctx.GetStub().SetEvent("MemberUnitsMigrated", eventJSON)
+
+	return report, nil
+}
+
+// SupplyMigrationReport summarizes one MigrateSupplyCounters run
+type SupplyMigrationReport struct {
+	WalletsCounted int    `json:"walletsCounted"`        // Wallets counted by this run
+	Cursor         string `json:"cursor"`                // Last wallet counted so far
+	Circulating    string `json:"circulating,omitempty"` // Sum of all balances, taken over as minted (once complete)
+	Agers          int    `json:"agers"`
+	Complete       bool   `json:"complete"` // false if the limit was reached and another run is needed
+	Timestamp      string `json:"timestamp"`
+}
+
+// SupplyMigration holds the running sums of a MigrateSupplyCounters spread over several transactions.
+// While it exists no wallet can be written, so no balance moves between a counted and an uncounted wallet.
+type SupplyMigration struct {
+	DocType        string           `json:"docType"`
+	Cursor         string           `json:"cursor"` // Wallets are counted in walletId order, up to and including this one
+	WalletsCounted int              `json:"walletsCounted"`
+	Total          int64            `json:"total"`        // Minor units
+	AgerBalances   map[string]int64 `json:"agerBalances"` // Minor units by Ager ID
+	StartedAt      string           `json:"startedAt"`
+}
+
+// supplyMigrationKey returns the state key of a running supply counter migration
+func supplyMigrationKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("supply", []string{"migration"})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// getSupplyMigration reads the running supply counter migration, nil if none is running
+func getSupplyMigration(ctx contractapi.TransactionContextInterface) (*SupplyMigration, error) {
+	key, err := supplyMigrationKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	migrationJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supply migration: %v", err)
+	}
+	if migrationJSON == nil {
+		return nil, nil
+	}
+
+	var migration SupplyMigration
+	if err := json.Unmarshal(migrationJSON, &migration); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal supply migration: %v", err)
+	}
+	return &migration, nil
+}
+
+// checkNoSupplyMigration rejects wallet writes while MigrateSupplyCounters is only partly done
+func checkNoSupplyMigration(ctx contractapi.TransactionContextInterface) error {
+	migration, err := getSupplyMigration(ctx)
+	if err != nil {
+		return err
+	}
+	if migration != nil {
+		return fmt.Errorf("wallets are locked until MigrateSupplyCounters is complete")
+	}
+	return nil
+}
+
+// MigrateSupplyCounters starts the supply counters of a ledger from its current balances (Orbis admin only).
+// Everything held so far counts as minted, per Ager as held by its wallets. Mints and burns fail until it is complete;
+// on a new ledger run it once on the empty state. At most limit wallets are counted per call (0 = unlimited),
+// the next call continues after the reported cursor and wallets stay locked until the last call writes the counters.
+func (s *SmartContract) MigrateSupplyCounters(ctx contractapi.TransactionContextInterface, limit int) (*SupplyMigrationReport, error) {
+	// Admin check
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can migrate supply counters")
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	_, found, err := getSupplyCounter(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, fmt.Errorf("supply counters are already maintained")
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	updatedAt := now.Format(time.RFC3339)
+
+	migration, err := getSupplyMigration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if migration == nil {
+		migration = &SupplyMigration{DocType: "supplyMigration", AgerBalances: map[string]int64{}, StartedAt: updatedAt}
+	}
+
+	// Wallets in walletId order after the cursor; the wallet ID is the state key, so the order is stable
+	query := newCouchQuery("wallet").sortBy("docType", false).sortBy("walletId", false).useIndex("indexWalletDoc", "indexWallet")
+	if migration.Cursor != "" {
+		query.op("walletId", "$gt", migration.Cursor)
+	}
+	queryString, err := query.build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	report := &SupplyMigrationReport{Complete: true, Timestamp: updatedAt}
+	for resultsIterator.HasNext() {
+		if limit > 0 && report.WalletsCounted >= limit {
+			report.Complete = false
+			break
+		}
+
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := checkSchemaVersion(queryResponse.Value); err != nil {
+			return nil, fmt.Errorf("wallet %s: %v", queryResponse.Key, err)
+		}
+
+		var wallet Wallet
+		if err := json.Unmarshal(queryResponse.Value, &wallet); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wallet %s: %v", queryResponse.Key, err)
+		}
+
+		if migration.Total, err = addAmounts(migration.Total, wallet.Balance); err != nil {
+			return nil, err
+		}
+		if agerID := walletAger(&wallet); agerID != "" {
+			if migration.AgerBalances[agerID], err = addAmounts(migration.AgerBalances[agerID], wallet.Balance); err != nil {
+				return nil, err
+			}
+		}
+		migration.Cursor = wallet.WalletID
+		migration.WalletsCounted++
+		report.WalletsCounted++
+	}
+
+	report.Cursor = migration.Cursor
+	report.Agers = len(migration.AgerBalances)
+
+	key, err := supplyMigrationKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if !report.Complete {
+		migrationJSON, err := json.Marshal(migration)
+		if err != nil {
+			return nil, err
+		}
+		if err := ctx.GetStub().PutState(key, migrationJSON); err != nil {
+			return nil, fmt.Errorf("failed to save supply migration: %v", err)
+		}
+		return report, nil
+	}
+
+	global := &SupplyCounter{DocType: "supply", Minted: migration.Total, Circulating: migration.Total, UpdatedAt: updatedAt}
+	if err := putSupplyCounter(ctx, global); err != nil {
+		return nil, err
+	}
+	for agerID, balance := range migration.AgerBalances {
+		counter := &SupplyCounter{DocType: "supply", AgerID: agerID, Minted: balance, Circulating: balance, UpdatedAt: updatedAt}
+		if err := putSupplyCounter(ctx, counter); err != nil {
+			return nil, err
+		}
+	}
+	if err := ctx.GetStub().DelState(key); err != nil {
+		return nil, fmt.Errorf("failed to delete supply migration: %v", err)
+	}
+
+	report.Circulating = formatAmount(migration.Total)
+
+	eventJSON, _ := json.Marshal(report)
+	_ = ctx.GetStub().SetEvent("SupplyCountersMigrated", eventJSON)
+
+	return report, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// testTxTime is the transaction time of every mock transaction
+var testTxTime = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+// testIdentity is a client identity with an X.509 subject CN, an MSP and attributes
+type testIdentity struct {
+	cn    string
+	mspID string
+	attrs map[string]string
+}
+
+// newTestIdentity returns an identity with the given role attribute ("" for none)
+func newTestIdentity(cn string, mspID string, role string) *testIdentity {
+	attrs := make(map[string]string)
+	if role != "" {
+		attrs["role"] = role
+	}
+	return &testIdentity{cn: cn, mspID: mspID, attrs: attrs}
+}
+
+func (c *testIdentity) GetID() (string, error) {
+	return base64.StdEncoding.EncodeToString([]byte("x509::CN=" + c.cn + "::CN=ca." + c.mspID)), nil
+}
+
+func (c *testIdentity) GetMSPID() (string, error) {
+	return c.mspID, nil
+}
+
+func (c *testIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	value, found := c.attrs[attrName]
+	return value, found, nil
+}
+
+func (c *testIdentity) AssertAttributeValue(attrName string, attrValue string) error {
+	if c.attrs[attrName] != attrValue {
+		return fmt.Errorf("attribute %s is not %s", attrName, attrValue)
+	}
+	return nil
+}
+
+func (c *testIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return &x509.Certificate{}, nil
+}
+
+// testLedger runs contract calls against a mock stub, one mock transaction per call
+type testLedger struct {
+	t    *testing.T
+	stub *shimtest.MockStub
+	s    *SmartContract
+	txn  int
+}
+
+func newTestLedger(t *testing.T) *testLedger {
+	return &testLedger{t: t, stub: shimtest.NewMockStub("jedo-wallet", nil), s: &SmartContract{}}
+}
+
+// invoke runs fn in a new mock transaction as identity. The mock stub writes through, so a failing
+// call leaves its writes behind where the peer would discard the transaction.
+func (l *testLedger) invoke(identity *testIdentity, fn func(ctx contractapi.TransactionContextInterface) error) error {
+	l.txn++
+	txID := fmt.Sprintf("tx%d", l.txn)
+	l.stub.MockTransactionStart(txID)
+	defer l.stub.MockTransactionEnd(txID)
+	l.stub.TxTimestamp = timestamppb.New(testTxTime)
+
+	ctx := &contractapi.TransactionContext{}
+	ctx.SetStub(l.stub)
+	ctx.SetClientIdentity(identity)
+	return fn(ctx)
+}
+
+// admin runs fn as an admin of the Ager alps
+func (l *testLedger) admin(fn func(ctx contractapi.TransactionContextInterface) error) error {
+	return l.invoke(newTestIdentity("admin.alps.ea.jedo.dev", "alps", "admin"), fn)
+}
+
+// must fails the test on an error of a setup step
+func (l *testLedger) must(err error) {
+	l.t.Helper()
+	if err != nil {
+		l.t.Fatal(err)
+	}
+}
+
+// putWallet stores an active JEDO wallet and lists it with its owner
+func (l *testLedger) putWallet(wallet *Wallet) {
+	l.t.Helper()
+	wallet.DocType = "wallet"
+	wallet.Currency = "JEDO"
+	if wallet.Status == "" {
+		wallet.Status = "active"
+	}
+	wallet.SchemaVersion = currentSchemaVersion
+	l.must(l.admin(func(ctx contractapi.TransactionContextInterface) error {
+		if err := putWalletState(ctx, wallet); err != nil {
+			return err
+		}
+		_, err := addOwnerWallets(ctx, wallet)
+		return err
+	}))
+}
+
+// balance returns the balance of a wallet in minor units
+func (l *testLedger) balance(walletID string) int64 {
+	l.t.Helper()
+	var wallet *Wallet
+	l.must(l.admin(func(ctx contractapi.TransactionContextInterface) error {
+		var err error
+		wallet, err = l.s.GetWallet(ctx, walletID)
+		return err
+	}))
+	return wallet.Balance
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// BTCMint is the public record of JEDO minted against a Bitcoin transaction output
+type BTCMint struct {
+	DocType        string `json:"docType"`
+	BTCTxID        string `json:"btcTxId"`       // Bitcoin transaction ID (lowercase hex)
+	OutputIndex    int    `json:"outputIndex"`   // Output index (vout) within the Bitcoin transaction
+	BTCSats        int64  `json:"btcSats"`       // Received amount in sats
+	AgerID         string `json:"agerId"`        // Ager operating the on-ramp
+	WalletID       string `json:"walletId"`      // Credited wallet
+	JedoAmount     int64  `json:"jedoAmount"`    // Minted JEDO in minor units
+	JedoPerBigMac  int64  `json:"jedoPerBigMac"` // Index values used for the conversion
+	SatsPerBigMac  int64  `json:"satsPerBigMac"`
+	IndexValidFrom string `json:"indexValidFrom"`
+	MintedBy       string `json:"mintedBy"`
+	TxID           string `json:"txId"`
+	Timestamp      string `json:"timestamp"`
+}
+
+// btcMintKey returns the key under which the mint of a Bitcoin output is recorded
+func btcMintKey(ctx contractapi.TransactionContextInterface, btcTxID string, outputIndex int) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("btcMint", []string{btcTxID, strconv.Itoa(outputIndex)})
+}
+
+// validateBTCTxID validates and normalizes a Bitcoin transaction ID
+func validateBTCTxID(btcTxID string) (string, error) {
+	btcTxID = strings.ToLower(strings.TrimSpace(btcTxID))
+	if len(btcTxID) != 64 {
+		return "", fmt.Errorf("BTC transaction ID must be 64 hex characters")
+	}
+	for _, c := range btcTxID {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return "", fmt.Errorf("BTC transaction ID must be 64 hex characters")
+		}
+	}
+	return btcTxID, nil
+}
+
+// MintFromBTC mints JEDO for BTC received by an Ager's on-ramp wallet (admin of that Ager only).
+// The JEDO amount follows the Ager's BigMac index and is credited to a wallet of the same Ager;
+// each Bitcoin output can be minted only once.
+func (s *SmartContract) MintFromBTC(
+	ctx contractapi.TransactionContextInterface,
+	agerID string,
+	btcTxID string,
+	outputIndex int,
+	btcAmount string,
+	walletID string,
+) (*BTCMint, error) {
+	// Admin check, an admin of another Ager's MSP must not mint against this Ager's on-ramp
+	if !isAgerAdmin(ctx, agerID) {
+		return nil, fmt.Errorf("only an admin of ager %s can mint from BTC", agerID)
+	}
+
+	if err := validateUnitID("ager", agerID); err != nil {
+		return nil, err
+	}
+	if err := checkAgerNotSuspended(ctx, agerID); err != nil {
+		return nil, err
+	}
+	btcTxID, err := validateBTCTxID(btcTxID)
+	if err != nil {
+		return nil, err
+	}
+	if outputIndex < 0 {
+		return nil, fmt.Errorf("output index cannot be negative")
+	}
+	sats, err := parseBTCAmount(btcAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	// Refuse to mint the same Bitcoin output twice
+	mintKey, err := btcMintKey(ctx, btcTxID, outputIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	existing, err := ctx.GetStub().GetState(mintKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return nil, fmt.Errorf("BTC output %s:%d has already been minted", btcTxID, outputIndex)
+	}
+
+	index, err := s.GetBigMacIndex(ctx, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if index.SatsPerBigMac <= 0 {
+		return nil, fmt.Errorf("BigMac index of ager %s has no sats price, on-ramp is disabled", agerID)
+	}
+
+	jedoAmount, err := mulDiv(sats, index.JedoPerBigMac, index.SatsPerBigMac)
+	if err != nil {
+		return nil, err
+	}
+	if jedoAmount <= 0 {
+		return nil, fmt.Errorf("BTC amount is too small to mint any JEDO")
+	}
+
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if walletAger(wallet) != agerID {
+		return nil, fmt.Errorf("wallet %s does not belong to ager %s", walletID, agerID)
+	}
+
+	counterparty := fmt.Sprintf("btc:%s:%d", btcTxID, outputIndex)
+	if err := s.mintToWallet(ctx, wallet, jedoAmount, "mint_btc", counterparty, "BTC on-ramp mint"); err != nil {
+		return nil, err
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, err
+	}
+
+	mint := &BTCMint{
+		DocType:        "btcMint",
+		BTCTxID:        btcTxID,
+		OutputIndex:    outputIndex,
+		BTCSats:        sats,
+		AgerID:         agerID,
+		WalletID:       walletID,
+		JedoAmount:     jedoAmount,
+		JedoPerBigMac:  index.JedoPerBigMac,
+		SatsPerBigMac:  index.SatsPerBigMac,
+		IndexValidFrom: index.ValidFrom,
+		MintedBy:       callerID,
+		TxID:           ctx.GetStub().GetTxID(),
+		Timestamp:      wallet.UpdatedAt,
+	}
+
+	mintJSON, err := json.Marshal(mint)
+	if err != nil {
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(mintKey, mintJSON); err != nil {
+		return nil, fmt.Errorf("failed to save BTC mint: %v", err)
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"btcTxId":     btcTxID,
+		"outputIndex": outputIndex,
+		"btcSats":     sats,
+		"agerId":      agerID,
+		"walletId":    walletID,
+		"jedoAmount":  formatAmount(jedoAmount),
+		"timestamp":   mint.Timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("BTCMinted", eventJSON)
+
+	return mint, nil
+}
+
+// GetBTCMint returns the mint record of a Bitcoin output (public)
+func (s *SmartContract) GetBTCMint(ctx contractapi.TransactionContextInterface, btcTxID string, outputIndex int) (*BTCMint, error) {
+	btcTxID, err := validateBTCTxID(btcTxID)
+	if err != nil {
+		return nil, err
+	}
+
+	mintKey, err := btcMintKey(ctx, btcTxID, outputIndex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	mintJSON, err := ctx.GetStub().GetState(mintKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if mintJSON == nil {
+		return nil, fmt.Errorf("BTC output %s:%d has not been minted", btcTxID, outputIndex)
+	}
+
+	var mint BTCMint
+	if err := json.Unmarshal(mintJSON, &mint); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal BTC mint: %v", err)
+	}
+
+	return &mint, nil
+}
+
+// GetBTCMints returns all BTC mint records, optionally restricted to one Ager (public, for community cross-checks)
+func (s *SmartContract) GetBTCMints(ctx contractapi.TransactionContextInterface, agerID string) ([]*BTCMint, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("btcMint", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get BTC mints: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var mints []*BTCMint
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var mint BTCMint
+		err = json.Unmarshal(queryResponse.Value, &mint)
+		if err != nil {
+			return nil, err
+		}
+
+		if agerID != "" && mint.AgerID != agerID {
+			continue
+		}
+
+		mints = append(mints, &mint)
+	}
+
+	return mints, nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// OwnerWallets lists the wallets of an owner under a key of its own, so submits read them with GetState.
+// A rich query is not re-executed at commit, a wallet created concurrently would go unnoticed; this key
+// is written by every wallet creation and therefore invalidates such a transaction instead.
+// The Ager and owner type let SettleTaxes page through the humans of an Ager.
+type OwnerWallets struct {
+	DocType   string   `json:"docType"`
+	OwnerID   string   `json:"ownerId"`
+	OwnerType string   `json:"ownerType"`        // human, gens, or ager/regnum/orbis for treasuries
+	AgerID    string   `json:"agerId,omitempty"` // Ager of the owner, empty for regnum and orbis treasuries
+	WalletIDs []string `json:"walletIds"`
+}
+
+// ownerWalletsKey returns the state key of the wallet list of an owner
+func ownerWalletsKey(ctx contractapi.TransactionContextInterface, ownerID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("ownerWallets", []string{ownerID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// getOwnerWalletIDs reads the wallet list of an owner, empty if the owner has no wallets
+func getOwnerWalletIDs(ctx contractapi.TransactionContextInterface, ownerID string) (*OwnerWallets, error) {
+	key, err := ownerWalletsKey(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	listJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read wallets of owner %s: %v", ownerID, err)
+	}
+	if listJSON == nil {
+		return &OwnerWallets{DocType: "ownerWallets", OwnerID: ownerID, WalletIDs: []string{}}, nil
+	}
+
+	var list OwnerWallets
+	if err := json.Unmarshal(listJSON, &list); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal wallets of owner %s: %v", ownerID, err)
+	}
+	return &list, nil
+}
+
+// addOwnerWallets adds wallets of one owner to the owner's wallet list; wallets already listed are skipped.
+// The list may be written only once per transaction, so pass all new wallets of the owner together.
+func addOwnerWallets(ctx contractapi.TransactionContextInterface, wallets ...*Wallet) (int, error) {
+	if len(wallets) == 0 {
+		return 0, nil
+	}
+	ownerID := wallets[0].OwnerID
+	list, err := getOwnerWalletIDs(ctx, ownerID)
+	if err != nil {
+		return 0, err
+	}
+
+	list.OwnerType = wallets[0].OwnerType
+	if list.OwnerType == "" {
+		list.OwnerType = levelHuman
+	}
+	list.AgerID = walletAger(wallets[0])
+
+	listed := make(map[string]bool)
+	for _, walletID := range list.WalletIDs {
+		listed[walletID] = true
+	}
+	added := 0
+	for _, wallet := range wallets {
+		if wallet.OwnerID != ownerID {
+			return 0, fmt.Errorf("wallet %s does not belong to owner %s", wallet.WalletID, ownerID)
+		}
+		if listed[wallet.WalletID] {
+			continue
+		}
+		listed[wallet.WalletID] = true
+		list.WalletIDs = append(list.WalletIDs, wallet.WalletID)
+		added++
+	}
+	if added == 0 {
+		return 0, nil
+	}
+
+	listJSON, err := json.Marshal(list)
+	if err != nil {
+		return 0, err
+	}
+	key, err := ownerWalletsKey(ctx, ownerID)
+	if err != nil {
+		return 0, err
+	}
+	if err := ctx.GetStub().PutState(key, listJSON); err != nil {
+		return 0, fmt.Errorf("failed to save wallets of owner %s: %v", ownerID, err)
+	}
+	return added, nil
+}
+
+// getOwnerWallets returns all wallets of an owner (internal, no access control).
+// It reads the wallet list and every wallet by key, which is safe inside a submit.
+func getOwnerWallets(ctx contractapi.TransactionContextInterface, ownerID string) ([]*Wallet, error) {
+	list, err := getOwnerWalletIDs(ctx, ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var wallets []*Wallet
+	for _, walletID := range list.WalletIDs {
+		walletJSON, err := ctx.GetStub().GetState(walletID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read wallet %s: %v", walletID, err)
+		}
+		if walletJSON == nil {
+			return nil, fmt.Errorf("wallet %s of owner %s does not exist", walletID, ownerID)
+		}
+
+		var wallet Wallet
+		if err := json.Unmarshal(walletJSON, &wallet); err != nil {
+			return nil, err
+		}
+
+		wallets = append(wallets, &wallet)
+	}
+
+	return wallets, nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// maxPageSize limits a page so a response stays within the peer query limits
+const maxPageSize = 500
+
+// WalletPage is a page of wallets. Pass Bookmark to the next call, an empty bookmark starts at the beginning;
+// a page with FetchedCount < PageSize is the last one. GensPage and TransactionPage work the same way.
+type WalletPage struct {
+	Wallets      []*Wallet `json:"wallets"`
+	PageSize     int32     `json:"pageSize"`
+	Bookmark     string    `json:"bookmark"`
+	FetchedCount int32     `json:"fetchedCount"`
+}
+
+// GensPage is a page of gens
+type GensPage struct {
+	Gens         []*Gens `json:"gens"`
+	PageSize     int32   `json:"pageSize"`
+	Bookmark     string  `json:"bookmark"`
+	FetchedCount int32   `json:"fetchedCount"`
+}
+
+// TransactionPage is a page of transaction records
+type TransactionPage struct {
+	Transactions []*Transaction `json:"transactions"`
+	PageSize     int32          `json:"pageSize"`
+	Bookmark     string         `json:"bookmark"`
+	FetchedCount int32          `json:"fetchedCount"`
+}
+
+// validatePageSize checks the requested page size
+func validatePageSize(pageSize int32) error {
+	if pageSize < 1 || pageSize > maxPageSize {
+		return fmt.Errorf("page size must be between 1 and %d", maxPageSize)
+	}
+	return nil
+}
+
+// GetAllWalletsPage returns a page of all wallets (admin only)
+func (s *SmartContract) GetAllWalletsPage(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*WalletPage, error) {
+	// Admin check
+	if !isAdmin(ctx) {
+		return nil, fmt.Errorf("only admin can list all wallets")
+	}
+
+	return queryWalletPage(ctx, `{"selector":{"docType":"wallet"}}`, pageSize, bookmark, nil)
+}
+
+// GetWalletsByGensPage returns a page of the wallets of the humans of a gens (admin or that gens).
+// For a gens, wallets of a gens with the same name in another Ager are dropped, so a page may hold fewer than FetchedCount wallets.
+func (s *SmartContract) GetWalletsByGensPage(ctx contractapi.TransactionContextInterface, gensID string, pageSize int32, bookmark string) (*WalletPage, error) {
+	belongsToGens, err := authorizeGensQuery(ctx, gensID)
+	if err != nil {
+		return nil, err
+	}
+
+	queryString, err := walletsByGensQuery(gensID)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryWalletPage(ctx, queryString, pageSize, bookmark, belongsToGens)
+}
+
+// GetWalletsByHumanPage returns a page of the wallets of a human (admin or that human)
+func (s *SmartContract) GetWalletsByHumanPage(ctx contractapi.TransactionContextInterface, humanID string, pageSize int32, bookmark string) (*WalletPage, error) {
+	if err := authorizeHumanQuery(ctx, humanID); err != nil {
+		return nil, err
+	}
+
+	queryString, err := walletsByHumanQuery(humanID)
+	if err != nil {
+		return nil, err
+	}
+
+	return queryWalletPage(ctx, queryString, pageSize, bookmark, nil)
+}
+
+// ListGensPage returns a page of the registered gens (admin only)
+func (s *SmartContract) ListGensPage(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*GensPage, error) {
+	if !isAdmin(ctx) {
+		return nil, fmt.Errorf("only admin can list gens")
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(`{"selector":{"docType":"gens"}}`, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query gens: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	nextBookmark, fetched := pageMetadata(metadata)
+	page := &GensPage{Gens: []*Gens{}, PageSize: pageSize, Bookmark: nextBookmark, FetchedCount: fetched}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var gens Gens
+		err = json.Unmarshal(queryResponse.Value, &gens)
+		if err != nil {
+			return nil, err
+		}
+
+		page.Gens = append(page.Gens, &gens)
+	}
+
+	return page, nil
+}
+
+// GetWalletHistoryPage returns a page of the transaction records of a wallet (only owner or admin)
+func (s *SmartContract) GetWalletHistoryPage(ctx contractapi.TransactionContextInterface, walletID string, pageSize int32, bookmark string) (*TransactionPage, error) {
+	wallet, err := s.authorizeWalletHistory(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination("transaction", []string{walletID}, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction history: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	nextBookmark, fetched := pageMetadata(metadata)
+	page := &TransactionPage{Transactions: []*Transaction{}, PageSize: pageSize, Bookmark: nextBookmark, FetchedCount: fetched}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var tx Transaction
+		err = json.Unmarshal(queryResponse.Value, &tx)
+		if err != nil {
+			return nil, err
+		}
+		mergeTransactionPrivateDetails(ctx, wallet, &tx, queryResponse.Key)
+
+		page.Transactions = append(page.Transactions, &tx)
+	}
+
+	return page, nil
+}
+
+// queryWalletPage runs a paginated wallet query, keep drops wallets from the page (nil keeps all)
+func queryWalletPage(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string, keep func(*Wallet) bool) (*WalletPage, error) {
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query wallets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	nextBookmark, fetched := pageMetadata(metadata)
+	page := &WalletPage{Wallets: []*Wallet{}, PageSize: pageSize, Bookmark: nextBookmark, FetchedCount: fetched}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var wallet Wallet
+		err = json.Unmarshal(queryResponse.Value, &wallet)
+		if err != nil {
+			return nil, err
+		}
+		if wallet.Metadata == nil {
+			wallet.Metadata = make(map[string]string)
+		}
+		if keep != nil && !keep(&wallet) {
+			continue
+		}
+
+		page.Wallets = append(page.Wallets, &wallet)
+	}
+
+	return page, nil
+}
+
+// pageMetadata returns the next bookmark and the number of fetched records from the peer response
+func pageMetadata(metadata *peer.QueryResponseMetadata) (string, int32) {
+	if metadata == nil {
+		return "", 0
+	}
+	return metadata.Bookmark, metadata.FetchedRecordsCount
+}
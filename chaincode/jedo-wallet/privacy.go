@@ -0,0 +1,368 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// PrivateDataMode records whether wallet metadata and transaction details go to private data collections.
+// Wallet balances stay public: peers of both Agers simulate a transfer and must read the same balances.
+type PrivateDataMode struct {
+	DocType   string `json:"docType"`
+	Enabled   bool   `json:"enabled"`
+	EnabledBy string `json:"enabledBy,omitempty"`
+	EnabledAt string `json:"enabledAt,omitempty"`
+}
+
+// minSaltLength is the minimum length of the transient salt of private data writes
+const minSaltLength = 16
+
+// WalletPrivateDetails holds the metadata of a wallet in the collection of its Ager
+type WalletPrivateDetails struct {
+	DocType  string            `json:"docType"`
+	WalletID string            `json:"walletId"`
+	Metadata map[string]string `json:"metadata"`
+	Salt     string            `json:"salt"` // Random per document, keeps the public privateHash from being brute-forced
+}
+
+// TransactionPrivateDetails holds the balance and description of a transaction record in the collection of its Ager
+type TransactionPrivateDetails struct {
+	DocType     string `json:"docType"`
+	TxID        string `json:"txId"`
+	WalletID    string `json:"walletId"`
+	Type        string `json:"type"`
+	Balance     int64  `json:"balance"` // Balance after transaction in minor units
+	Description string `json:"description"`
+	Salt        string `json:"salt"` // Random per document, keeps the public privateHash from being brute-forced
+}
+
+// privateDataModeKey returns the state key of the private data mode
+func privateDataModeKey(ctx contractapi.TransactionContextInterface) (string, error) {
+	return ctx.GetStub().CreateCompositeKey("config", []string{"privateDataMode"})
+}
+
+// agerCollection returns the private data collection of an Ager (generated per Ager by writeCollectionsConfig in infrastructure/dev/cc_utils.sh)
+func agerCollection(agerID string) string {
+	return "private_" + agerID
+}
+
+// walletCollection returns the private data collection for a wallet, "" for treasuries which stay public
+func walletCollection(wallet *Wallet) string {
+	path, err := parseOwnerPath(wallet.OwnerID, wallet.OwnerType)
+	if err != nil {
+		return ""
+	}
+	return agerCollection(path.Ager)
+}
+
+// EnablePrivateDataMode moves wallet metadata and transaction details of new writes to private data collections
+// (admin only, cannot be undone, existing documents are not moved)
+func (s *SmartContract) EnablePrivateDataMode(ctx contractapi.TransactionContextInterface) error {
+	// Admin check
+	if !isAdmin(ctx) {
+		return fmt.Errorf("only admin can enable the private data mode")
+	}
+
+	mode, err := getPrivateDataMode(ctx)
+	if err != nil {
+		return err
+	}
+	if mode.Enabled {
+		return fmt.Errorf("private data mode is already enabled")
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	mode.Enabled = true
+	mode.EnabledBy = callerID
+	mode.EnabledAt = now.Format(time.RFC3339)
+
+	modeJSON, err := json.Marshal(mode)
+	if err != nil {
+		return err
+	}
+
+	key, err := privateDataModeKey(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(key, modeJSON); err != nil {
+		return fmt.Errorf("failed to save private data mode: %v", err)
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"enabledBy": callerID,
+		"timestamp": mode.EnabledAt,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("PrivateDataModeEnabled", eventJSON)
+
+	return nil
+}
+
+// GetPrivateDataMode returns whether the private data mode is enabled (public)
+func (s *SmartContract) GetPrivateDataMode(ctx contractapi.TransactionContextInterface) (*PrivateDataMode, error) {
+	return getPrivateDataMode(ctx)
+}
+
+// getPrivateDataMode reads the private data mode, disabled if it was never set
+func getPrivateDataMode(ctx contractapi.TransactionContextInterface) (*PrivateDataMode, error) {
+	key, err := privateDataModeKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	modeJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private data mode: %v", err)
+	}
+	if modeJSON == nil {
+		return &PrivateDataMode{DocType: "privateDataMode"}, nil
+	}
+
+	var mode PrivateDataMode
+	if err := json.Unmarshal(modeJSON, &mode); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal private data mode: %v", err)
+	}
+	return &mode, nil
+}
+
+// GetWalletPrivateDetails returns the private metadata of a wallet (only owner or admin, on a peer of the owning Ager)
+func (s *SmartContract) GetWalletPrivateDetails(ctx contractapi.TransactionContextInterface, walletID string) (*WalletPrivateDetails, error) {
+	callerRole, err := getCallerRole(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	if callerRole != "admin" && !caller.isOwner(wallet.OwnerID, wallet.OwnerType) {
+		return nil, fmt.Errorf("you can only view your own wallet details")
+	}
+	if wallet.PrivateHash == "" {
+		return nil, fmt.Errorf("wallet %s keeps its metadata on the public state", walletID)
+	}
+
+	key, err := walletPrivateKey(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+
+	collection := walletCollection(wallet)
+	detailsJSON, err := ctx.GetStub().GetPrivateData(collection, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private data from %s: %v", collection, err)
+	}
+	if detailsJSON == nil {
+		return nil, fmt.Errorf("private details of wallet %s are not available on this peer", walletID)
+	}
+
+	var details WalletPrivateDetails
+	if err := json.Unmarshal(detailsJSON, &details); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal private details: %v", err)
+	}
+	if details.Metadata == nil {
+		details.Metadata = make(map[string]string)
+	}
+	return &details, nil
+}
+
+// walletPrivateKey returns the private data key of the wallet details
+func walletPrivateKey(ctx contractapi.TransactionContextInterface, walletID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("walletPrivate", []string{walletID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// privateDataEnabled reports whether new writes use the private data collections
+func privateDataEnabled(ctx contractapi.TransactionContextInterface) (bool, error) {
+	mode, err := getPrivateDataMode(ctx)
+	if err != nil {
+		return false, err
+	}
+	return mode.Enabled, nil
+}
+
+// getSensitiveArg returns a sensitive argument, taken from the transient field name if present.
+// In private data mode the argument itself must be empty so its value never appears in the proposal.
+func getSensitiveArg(ctx contractapi.TransactionContextInterface, name string, value string) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+
+	if transientValue, found := transient[name]; found {
+		if value != "" {
+			return "", fmt.Errorf("%s must be passed either as argument or as transient data, not both", name)
+		}
+		return string(transientValue), nil
+	}
+
+	if value != "" {
+		enabled, err := privateDataEnabled(ctx)
+		if err != nil {
+			return "", err
+		}
+		if enabled {
+			return "", fmt.Errorf("%s must be passed as transient data in private data mode", name)
+		}
+	}
+	return value, nil
+}
+
+// putWalletPrivateDetails moves the metadata of a wallet into its private collection and keeps its hash on the wallet.
+// Without private data mode, or for treasuries, the metadata stays on the public wallet.
+func putWalletPrivateDetails(ctx contractapi.TransactionContextInterface, wallet *Wallet) error {
+	collection := walletCollection(wallet)
+	if collection == "" {
+		return nil
+	}
+	enabled, err := privateDataEnabled(ctx)
+	if err != nil || !enabled {
+		return err
+	}
+
+	key, err := walletPrivateKey(ctx, wallet.WalletID)
+	if err != nil {
+		return err
+	}
+
+	salt, err := privateSalt(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	details := WalletPrivateDetails{
+		DocType:  "walletPrivate",
+		WalletID: wallet.WalletID,
+		Metadata: wallet.Metadata,
+		Salt:     salt,
+	}
+	hash, err := putPrivateDoc(ctx, collection, key, details)
+	if err != nil {
+		return err
+	}
+
+	wallet.Metadata = make(map[string]string)
+	wallet.PrivateHash = hash
+	return nil
+}
+
+// putTransactionPrivateDetails moves the balance and description of a transaction record into the private
+// collection of its wallet and keeps their hash on the record
+func putTransactionPrivateDetails(ctx contractapi.TransactionContextInterface, wallet *Wallet, tx *Transaction, txKey string) error {
+	collection := walletCollection(wallet)
+	if collection == "" {
+		return nil
+	}
+	enabled, err := privateDataEnabled(ctx)
+	if err != nil || !enabled {
+		return err
+	}
+
+	salt, err := privateSalt(ctx, txKey)
+	if err != nil {
+		return err
+	}
+
+	details := TransactionPrivateDetails{
+		DocType:     "transactionPrivate",
+		TxID:        tx.TxID,
+		WalletID:    tx.WalletID,
+		Type:        tx.Type,
+		Balance:     tx.Balance,
+		Description: tx.Description,
+		Salt:        salt,
+	}
+	hash, err := putPrivateDoc(ctx, collection, txKey, details)
+	if err != nil {
+		return err
+	}
+
+	tx.Balance = 0
+	tx.Description = ""
+	tx.PrivateHash = hash
+	return nil
+}
+
+// mergeTransactionPrivateDetails fills in balance and description of a record from its private collection.
+// Peers outside the collection keep the public record.
+func mergeTransactionPrivateDetails(ctx contractapi.TransactionContextInterface, wallet *Wallet, tx *Transaction, txKey string) {
+	if tx.PrivateHash == "" {
+		return
+	}
+	collection := walletCollection(wallet)
+	if collection == "" {
+		return
+	}
+
+	detailsJSON, err := ctx.GetStub().GetPrivateData(collection, txKey)
+	if err != nil || detailsJSON == nil {
+		return
+	}
+
+	var details TransactionPrivateDetails
+	if err := json.Unmarshal(detailsJSON, &details); err != nil {
+		return
+	}
+	tx.Balance = details.Balance
+	tx.Description = details.Description
+}
+
+// privateSalt derives the salt of the private document key from the random transient field "salt".
+// Chaincode cannot draw random numbers (every endorser must compute the same write set), so the client supplies
+// the entropy; hashing it with the key gives each document of the transaction its own salt.
+func privateSalt(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return "", fmt.Errorf("failed to read transient data: %v", err)
+	}
+	salt := transient["salt"]
+	if len(salt) < minSaltLength {
+		return "", fmt.Errorf("private data mode requires a random transient field \"salt\" of at least %d bytes", minSaltLength)
+	}
+
+	hasher := sha256.New()
+	hasher.Write(salt)
+	hasher.Write([]byte{0})
+	hasher.Write([]byte(key))
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// putPrivateDoc writes a salted document to a private data collection and returns the SHA-256 of its JSON
+func putPrivateDoc(ctx contractapi.TransactionContextInterface, collection string, key string, doc interface{}) (string, error) {
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, key, docJSON); err != nil {
+		return "", fmt.Errorf("failed to write private data to %s: %v", collection, err)
+	}
+
+	hash := sha256.Sum256(docJSON)
+	return hex.EncodeToString(hash[:]), nil
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxPublicGoodInstallments limits the length of a payout schedule
+const maxPublicGoodInstallments = 120
+
+// PublicGoodPayout is one installment of an approved public-good project
+type PublicGoodPayout struct {
+	Installment int    `json:"installment"`
+	Amount      int64  `json:"amount"` // Minor units
+	Due         string `json:"due,omitempty"`
+	PaidAt      string `json:"paidAt,omitempty"`
+	TxID        string `json:"txId,omitempty"`
+}
+
+// CreatePublicGoodProposal proposes to mint amount JEDO for a public-good project (admin or eligible human of the unit).
+// With installments > 1 the amount is paid out in equal parts every intervalDays after approval.
+func (s *SmartContract) CreatePublicGoodProposal(
+	ctx contractapi.TransactionContextInterface,
+	proposalID string,
+	level string,
+	unitID string,
+	title string,
+	documentCID string,
+	votingDays int,
+	beneficiaryWalletID string,
+	amount string,
+	installments int,
+	intervalDays int,
+) (*Proposal, error) {
+	if err := validateLevel(level); err != nil {
+		return nil, err
+	}
+	if err := validateUnitID(level, unitID); err != nil {
+		return nil, err
+	}
+
+	amountMinor, err := parseAmount(amount)
+	if err != nil {
+		return nil, fmt.Errorf("invalid amount: %v", err)
+	}
+	if amountMinor <= 0 {
+		return nil, fmt.Errorf("amount must be positive")
+	}
+	if installments < 1 || installments > maxPublicGoodInstallments {
+		return nil, fmt.Errorf("installments must be between 1 and %d", maxPublicGoodInstallments)
+	}
+	if int64(installments) > amountMinor {
+		return nil, fmt.Errorf("amount is too small for %d installments", installments)
+	}
+	if installments > 1 && (intervalDays < 1 || intervalDays > 365) {
+		return nil, fmt.Errorf("interval days must be between 1 and 365")
+	}
+	if installments == 1 {
+		intervalDays = 0
+	}
+
+	beneficiary, err := s.GetWallet(ctx, beneficiaryWalletID)
+	if err != nil {
+		return nil, err
+	}
+	if beneficiary.Status != "active" {
+		return nil, fmt.Errorf("beneficiary wallet %s is not active", beneficiaryWalletID)
+	}
+
+	proposerID, err := s.getProposer(ctx, level, unitID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Equal installments, the remainder goes with the first one
+	payouts := make([]PublicGoodPayout, installments)
+	share := amountMinor / int64(installments)
+	for i := range payouts {
+		payouts[i] = PublicGoodPayout{Installment: i + 1, Amount: share}
+	}
+	payouts[0].Amount += amountMinor - share*int64(installments)
+
+	// An approved project must be payable: every installment has to fit the beneficiary's holding cap.
+	// The first installment is the largest, so checking it covers all of them.
+	if err := s.checkHoldingCap(ctx, beneficiary, payouts[0].Amount); err != nil {
+		return nil, fmt.Errorf("installment of %s cannot be paid out: %v", formatAmount(payouts[0].Amount), err)
+	}
+
+	return s.openProposal(ctx, &Proposal{
+		ProposalID:          proposalID,
+		Kind:                kindPublicGood,
+		Level:               level,
+		UnitID:              unitID,
+		Title:               title,
+		DocumentCID:         documentCID,
+		ProposerID:          proposerID,
+		VotingDays:          votingDays,
+		BeneficiaryWalletID: beneficiaryWalletID,
+		Amount:              amountMinor,
+		PayoutIntervalDays:  intervalDays,
+		Payouts:             payouts,
+	})
+}
+
+// ReleasePublicGoodPayouts mints all installments of an approved public-good project that are due (public, deterministic)
+func (s *SmartContract) ReleasePublicGoodPayouts(ctx contractapi.TransactionContextInterface, proposalID string) (*Proposal, error) {
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+	if proposal.Kind != kindPublicGood {
+		return nil, fmt.Errorf("proposal %s is not a public-good proposal", proposalID)
+	}
+	if proposal.Status != "passed" {
+		return nil, fmt.Errorf("proposal %s has not been approved", proposalID)
+	}
+
+	minted, err := s.releaseDuePayouts(ctx, proposal)
+	if err != nil {
+		return nil, err
+	}
+	if minted == 0 {
+		return nil, fmt.Errorf("no installment of proposal %s is due", proposalID)
+	}
+
+	if err := putProposal(ctx, proposal); err != nil {
+		return nil, err
+	}
+	return proposal, nil
+}
+
+// applyPublicGoodDecision schedules the installments of an approved project, the first one is due at approval.
+// Nothing is minted here: ReleasePublicGoodPayouts pays them out, so a failed mint cannot leave half-written state.
+func (s *SmartContract) applyPublicGoodDecision(ctx contractapi.TransactionContextInterface, proposal *Proposal) error {
+	if proposal.Status != "passed" {
+		return nil
+	}
+
+	decidedAt, err := time.Parse(time.RFC3339, proposal.DecidedAt)
+	if err != nil {
+		return fmt.Errorf("invalid decision time: %v", err)
+	}
+	for i := range proposal.Payouts {
+		proposal.Payouts[i].Due = decidedAt.AddDate(0, 0, i*proposal.PayoutIntervalDays).Format(time.RFC3339)
+	}
+
+	return putProposal(ctx, proposal)
+}
+
+// releaseDuePayouts mints the due and unpaid installments in one credit and marks them as paid.
+// Installments that would push the beneficiary above the holding cap stay unpaid for a later release,
+// so the ones that fit are not held back; only if not even the first one fits the release fails.
+func (s *SmartContract) releaseDuePayouts(ctx contractapi.TransactionContextInterface, proposal *Proposal) (int64, error) {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	wallet, err := s.GetWallet(ctx, proposal.BeneficiaryWalletID)
+	if err != nil {
+		return 0, err
+	}
+
+	var minted int64
+	var installments []int
+	for i, payout := range proposal.Payouts {
+		if payout.PaidAt != "" {
+			continue
+		}
+		due, err := time.Parse(time.RFC3339, payout.Due)
+		if err != nil || now.Before(due) {
+			continue
+		}
+		total, err := addAmounts(minted, payout.Amount)
+		if err != nil {
+			return 0, err
+		}
+		if len(installments) > 0 {
+			exceeds, _, err := s.exceedsHoldingCap(ctx, wallet, total)
+			if err != nil {
+				return 0, err
+			}
+			if exceeds {
+				break
+			}
+		}
+		minted = total
+		installments = append(installments, i)
+	}
+	if minted == 0 {
+		return 0, nil
+	}
+
+	description := fmt.Sprintf("Public good: %s", proposal.Title)
+	if err := s.mintToWallet(ctx, wallet, minted, "mint_public_good", "proposal:"+proposal.ProposalID, description); err != nil {
+		return 0, err
+	}
+
+	for _, i := range installments {
+		proposal.Payouts[i].PaidAt = now.Format(time.RFC3339)
+		proposal.Payouts[i].TxID = ctx.GetStub().GetTxID()
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"proposalId": proposal.ProposalID,
+		"walletId":   proposal.BeneficiaryWalletID,
+		"amount":     formatAmount(minted),
+		"timestamp":  now.Format(time.RFC3339),
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("PublicGoodMinted", eventJSON)
+
+	return minted, nil
+}
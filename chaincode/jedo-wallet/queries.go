@@ -3,98 +3,74 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// GetWalletHistory returns the transaction history for a wallet (only owner can view)
+// GetWalletHistory returns the latest limit transaction records of a wallet, newest first (only owner can view)
 func (s *SmartContract) GetWalletHistory(ctx contractapi.TransactionContextInterface, walletID string, limit int) ([]*Transaction, error) {
 	// Access control - only owner or admin
-	callerRole, err := getCallerRole(ctx)
+	wallet, err := s.authorizeWalletHistory(ctx, walletID)
 	if err != nil {
 		return nil, err
 	}
 
-	callerID, err := ctx.GetClientIdentity().GetID()
+	// Query transactions in chronological order
+	transactions, err := getWalletTransactions(ctx, walletID, &transactionFilter{}, limit)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if wallet exists
-	wallet, err := s.GetWallet(ctx, walletID)
+	for _, tx := range transactions {
+		txKey, err := transactionKey(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		mergeTransactionPrivateDetails(ctx, wallet, tx, txKey)
+	}
+
+	return transactions, nil
+}
+
+// authorizeWalletHistory returns the wallet if the caller may view its history (only owner or admin)
+func (s *SmartContract) authorizeWalletHistory(ctx contractapi.TransactionContextInterface, walletID string) (*Wallet, error) {
+	callerRole, err := getCallerRole(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	// Only wallet owner or admin can view history
-	if callerRole != "admin" && !strings.Contains(callerID, wallet.OwnerID) {
-		return nil, fmt.Errorf("you can only view your own wallet history")
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	// Query transactions using composite key
-	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("transaction", []string{walletID})
+	// Check if wallet exists
+	wallet, err := s.GetWallet(ctx, walletID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get transaction history: %v", err)
+		return nil, err
 	}
-	defer resultsIterator.Close()
 
-	var transactions []*Transaction
-	count := 0
-
-	for resultsIterator.HasNext() && (limit == 0 || count < limit) {
-		queryResponse, err := resultsIterator.Next()
-		if err != nil {
-			return nil, err
-		}
-
-		var tx Transaction
-		err = json.Unmarshal(queryResponse.Value, &tx)
-		if err != nil {
-			return nil, err
-		}
-
-		transactions = append(transactions, &tx)
-		count++
+	// Only wallet owner or admin can view history
+	if callerRole != "admin" && !caller.isOwner(wallet.OwnerID, wallet.OwnerType) {
+		return nil, fmt.Errorf("you can only view your own wallet history")
 	}
-
-	return transactions, nil
+	return wallet, nil
 }
 
 // GetWalletsByGens returns all wallets for humans belonging to a specific gens
 func (s *SmartContract) GetWalletsByGens(ctx contractapi.TransactionContextInterface, gensID string) ([]*Wallet, error) {
 	// Only gens or admin can query
-	callerRole, err := getCallerRole(ctx)
+	belongsToGens, err := authorizeGensQuery(ctx, gensID)
 	if err != nil {
 		return nil, err
 	}
 
-	if callerRole == "admin" {
-		// Admin OK
-	} else if callerRole == "gens" {
-		// Verify caller is the requested gens
-		callerID, err := ctx.GetClientIdentity().GetID()
-		if err != nil {
-			return nil, err
-		}
-
-		if !strings.Contains(callerID, gensID) {
-			return nil, fmt.Errorf("you can only query your own humans' wallets")
-		}
-	} else {
-		return nil, fmt.Errorf("only admin or gens can query wallets by gens")
+	queryString, err := walletsByGensQuery(gensID)
+	if err != nil {
+		return nil, err
 	}
 
-	// CouchDB rich query - match wallets where ownerId contains gensID
-	queryString := fmt.Sprintf(`{
-		"selector": {
-			"docType": "wallet",
-			"ownerId": {
-				"$regex": ".*\\.%s\\..*"
-			}
-		}
-	}`, gensID)
-
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query wallets: %v", err)
@@ -114,38 +90,60 @@ func (s *SmartContract) GetWalletsByGens(ctx contractapi.TransactionContextInter
 			return nil, err
 		}
 
+		if belongsToGens != nil && !belongsToGens(&wallet) {
+			continue
+		}
+
 		wallets = append(wallets, &wallet)
 	}
 
 	return wallets, nil
 }
 
-// GetWalletsByHuman returns all wallets belonging to a specific human
-func (s *SmartContract) GetWalletsByHuman(ctx contractapi.TransactionContextInterface, humanID string) ([]*Wallet, error) {
-	// Only human himself or admin can query
+// authorizeGensQuery checks that the caller may list the wallets of a gens (admin or that gens).
+// For a gens it returns a filter that keeps its humans and its treasury wallet and drops those of a gens
+// with the same name in another Ager.
+func authorizeGensQuery(ctx contractapi.TransactionContextInterface, gensID string) (func(*Wallet) bool, error) {
 	callerRole, err := getCallerRole(ctx)
 	if err != nil {
 		return nil, err
 	}
 
+	caller, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	if callerRole == "admin" {
-		// Admin OK
-	} else if callerRole == "human" {
-		// Verify caller is the requested human
-		callerID, _ := ctx.GetClientIdentity().GetID()
-		if !strings.Contains(callerID, humanID) {
-			return nil, fmt.Errorf("you can only query your own wallets")
+		return nil, nil
+	} else if callerRole == "gens" {
+		// Verify caller is the requested gens
+		if caller.firstLabel() != gensID {
+			return nil, fmt.Errorf("you can only query your own humans' wallets")
 		}
-	} else {
-		return nil, fmt.Errorf("only admin or human can query wallets by human")
+		return func(wallet *Wallet) bool {
+			return caller.isGensOf(wallet.OwnerID) || caller.isOwner(wallet.OwnerID, wallet.OwnerType)
+		}, nil
 	}
+	return nil, fmt.Errorf("only admin or gens can query wallets by gens")
+}
 
-	queryString := fmt.Sprintf(`{
-		"selector": {
-			"docType": "wallet",
-			"ownerId": "%s"
-		}
-	}`, humanID)
+// walletsByGensQuery returns the CouchDB query for the wallets of a gens (uses the stored gensId)
+func walletsByGensQuery(gensID string) (string, error) {
+	return newCouchQuery("wallet").eq("gensId", gensID).useIndex("indexGensDoc", "indexGens").build()
+}
+
+// GetWalletsByHuman returns all wallets belonging to a specific human
+func (s *SmartContract) GetWalletsByHuman(ctx contractapi.TransactionContextInterface, humanID string) ([]*Wallet, error) {
+	// Only human himself or admin can query
+	if err := authorizeHumanQuery(ctx, humanID); err != nil {
+		return nil, err
+	}
+
+	queryString, err := walletsByHumanQuery(humanID)
+	if err != nil {
+		return nil, err
+	}
 
 	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
@@ -172,6 +170,35 @@ func (s *SmartContract) GetWalletsByHuman(ctx contractapi.TransactionContextInte
 	return wallets, nil
 }
 
+// authorizeHumanQuery checks that the caller may list the wallets of a human (admin or that human)
+func authorizeHumanQuery(ctx contractapi.TransactionContextInterface, humanID string) error {
+	callerRole, err := getCallerRole(ctx)
+	if err != nil {
+		return err
+	}
+
+	if callerRole == "admin" {
+		// Admin OK
+	} else if callerRole == "human" {
+		// Verify caller is the requested human
+		caller, err := getCallerIdentity(ctx)
+		if err != nil {
+			return err
+		}
+		if !caller.isOwner(humanID, levelHuman) {
+			return fmt.Errorf("you can only query your own wallets")
+		}
+	} else {
+		return fmt.Errorf("only admin or human can query wallets by human")
+	}
+	return nil
+}
+
+// walletsByHumanQuery returns the CouchDB query for the wallets of a human
+func walletsByHumanQuery(humanID string) (string, error) {
+	return newCouchQuery("wallet").eq("ownerId", humanID).useIndex("indexOwnerDoc", "indexOwner").build()
+}
+
 // GetAllWallets returns all wallets (admin only)
 func (s *SmartContract) GetAllWallets(ctx contractapi.TransactionContextInterface) ([]*Wallet, error) {
 	// Admin check
@@ -210,24 +237,36 @@ func (s *SmartContract) GetAllWallets(ctx contractapi.TransactionContextInterfac
 	return wallets, nil
 }
 
-// GetTotalBalance returns the sum of all wallet balances (admin only)
-func (s *SmartContract) GetTotalBalance(ctx contractapi.TransactionContextInterface) (float64, error) {
+// GetTotalBalance returns the sum of all wallet balances (admin only).
+// It reads the global supply counter; ledgers without counters (see MigrateSupplyCounters) are scanned.
+func (s *SmartContract) GetTotalBalance(ctx contractapi.TransactionContextInterface) (string, error) {
 	// Admin check
 	if !isAdmin(ctx) {
-		return 0, fmt.Errorf("only admin can get total balance")
+		return "", fmt.Errorf("only admin can get total balance")
+	}
+
+	counter, found, err := getSupplyCounter(ctx, "")
+	if err != nil {
+		return "", err
+	}
+	if found {
+		return formatAmount(counter.Circulating), nil
 	}
 
 	wallets, err := s.GetAllWallets(ctx)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
-	var total float64
+	var total int64
 	for _, wallet := range wallets {
-		total += wallet.Balance
+		total, err = addAmounts(total, wallet.Balance)
+		if err != nil {
+			return "", err
+		}
 	}
 
-	return total, nil
+	return formatAmount(total), nil
 }
 
 // Gens represents a gens (business) entity
@@ -237,6 +276,8 @@ type Gens struct {
 	Name      string `json:"name"`
 	CreatedAt string `json:"createdAt"`
 	Status    string `json:"status"`
+	OwnerID   string `json:"ownerId,omitempty"`  // Gens identity (e.g., worb.alps.ea.jedo.cc)
+	WalletID  string `json:"walletId,omitempty"` // Treasury wallet of the gens
 }
 
 // ListGens returns all registered gens (admin only)
@@ -276,24 +317,142 @@ func (s *SmartContract) ListGens(ctx contractapi.TransactionContextInterface) ([
 	return gensList, nil
 }
 
-// RegisterGens creates a new gens entry (admin only)
-func (s *SmartContract) RegisterGens(ctx contractapi.TransactionContextInterface, gensID string, name string) error {
-	if !isAdmin(ctx) {
-		return fmt.Errorf("only admin can register gens")
+// RegisterGens creates a new gens entry with its treasury wallet (admin of the gens's Ager only).
+// ownerID is the gens identity (e.g., worb.alps.ea.jedo.cc), which owns the wallet and may transfer from it.
+// The wallet is created like a human wallet by CreateWallet, so a suspended Ager cannot register gens.
+func (s *SmartContract) RegisterGens(ctx contractapi.TransactionContextInterface, gensID string, name string, ownerID string, walletID string) error {
+	if err := checkGensAdmin(ctx, ownerID); err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(gensID)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if existing != nil {
+		return fmt.Errorf("gens %s already exists", gensID)
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
 	}
 
-	gens := Gens{
+	gens := &Gens{
 		DocType:   "gens",
 		GensID:    gensID,
 		Name:      name,
-		CreatedAt: getCurrentTimestamp(),
+		CreatedAt: now.Format(time.RFC3339),
 		Status:    "active",
 	}
+	return s.createGensWallet(ctx, gens, ownerID, walletID)
+}
+
+// CreateGensWallet creates the treasury wallet of a gens registered before gens had wallets (admin of the gens's Ager only)
+func (s *SmartContract) CreateGensWallet(ctx contractapi.TransactionContextInterface, gensID string, ownerID string, walletID string) error {
+	if err := checkGensAdmin(ctx, ownerID); err != nil {
+		return err
+	}
+
+	gensJSON, err := ctx.GetStub().GetState(gensID)
+	if err != nil {
+		return fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if gensJSON == nil {
+		return fmt.Errorf("gens %s does not exist", gensID)
+	}
+
+	var gens Gens
+	if err := json.Unmarshal(gensJSON, &gens); err != nil {
+		return fmt.Errorf("failed to unmarshal gens: %v", err)
+	}
+	if gens.WalletID != "" {
+		return fmt.Errorf("gens %s already has wallet %s", gensID, gens.WalletID)
+	}
+	return s.createGensWallet(ctx, &gens, ownerID, walletID)
+}
+
+// checkGensAdmin checks that the caller is an admin of the Ager of the gens identity ownerID
+func checkGensAdmin(ctx contractapi.TransactionContextInterface, ownerID string) error {
+	path, err := parseOwnerPath(ownerID, levelGens)
+	if err != nil {
+		return err
+	}
+	if !isAgerAdmin(ctx, path.Ager) {
+		return fmt.Errorf("only an admin of ager %s can register its gens", path.Ager)
+	}
+	return nil
+}
+
+// createGensWallet creates the treasury wallet of a gens, owned by the gens identity ownerID, and saves the gens
+func (s *SmartContract) createGensWallet(ctx contractapi.TransactionContextInterface, gens *Gens, ownerID string, walletID string) error {
+	if err := validateOwnerID(ownerID); err != nil {
+		return err
+	}
+	path, err := parseOwnerPath(ownerID, levelGens)
+	if err != nil {
+		return err
+	}
+	if path.Gens != gens.GensID {
+		return fmt.Errorf("owner %s is not the identity of gens %s", ownerID, gens.GensID)
+	}
+	if err := validateWalletID(walletID); err != nil {
+		return err
+	}
+	// The gens record is stored under its ID, the wallet would overwrite it in the same transaction
+	if walletID == gens.GensID {
+		return fmt.Errorf("wallet ID must differ from the gens ID %s", gens.GensID)
+	}
+	exists, err := s.WalletExists(ctx, walletID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("wallet %s already exists", walletID)
+	}
+
+	gens.OwnerID = ownerID
+	gens.WalletID = walletID
 
 	gensJSON, err := json.Marshal(gens)
 	if err != nil {
 		return err
 	}
+	if err := ctx.GetStub().PutState(gens.GensID, gensJSON); err != nil {
+		return fmt.Errorf("failed to save gens: %v", err)
+	}
+
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	now := txTime.Format(time.RFC3339)
+	wallet := Wallet{
+		DocType:       "wallet",
+		WalletID:      walletID,
+		OwnerID:       ownerID,
+		OwnerType:     levelGens,
+		GensID:        path.Gens,
+		Currency:      "JEDO",
+		Status:        "active",
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Metadata:      make(map[string]string),
+		SchemaVersion: currentSchemaVersion,
+	}
+	if err := createWalletState(ctx, &wallet); err != nil {
+		return err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"walletId":       walletID,
+		"ownerId":        ownerID,
+		"initialBalance": formatAmount(0),
+		"timestamp":      now,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("WalletCreated", eventJSON)
 
-	return ctx.GetStub().PutState(gensID, gensJSON)
+	return nil
 }
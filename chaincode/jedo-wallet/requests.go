@@ -0,0 +1,162 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// maxRequestIDLength limits client request IDs, a UUID fits easily
+const maxRequestIDLength = 128
+
+// ClientRequest records a client request ID so a retried submission is applied only once.
+// The ID is passed as transient field "requestId" and is scoped to the submitting identity (MSP ID and CN),
+// so a CN enrolled by another Ager's CA cannot read or block the requests of the real owner.
+type ClientRequest struct {
+	DocType     string `json:"docType"`
+	RequestID   string `json:"requestId"`
+	MSPID       string `json:"mspId"`    // MSP of the submitting identity
+	ClientID    string `json:"clientId"` // CN of the submitting identity
+	Function    string `json:"function"`
+	Fingerprint string `json:"fingerprint"` // SHA-256 of function and arguments
+	TxID        string `json:"txId"`        // Transaction that applied the request
+	Timestamp   string `json:"timestamp"`
+}
+
+// clientRequestKey returns the state key of a request ID of a client
+func clientRequestKey(ctx contractapi.TransactionContextInterface, client *clientIdentity, requestID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("clientRequest", []string{client.MSPID, client.CN, requestID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// validateRequestID checks a client request ID
+func validateRequestID(requestID string) error {
+	if strings.TrimSpace(requestID) == "" {
+		return fmt.Errorf("request ID cannot be empty")
+	}
+	if len(requestID) > maxRequestIDLength {
+		return fmt.Errorf("request ID must not exceed %d characters", maxRequestIDLength)
+	}
+	if !utf8.ValidString(requestID) || strings.ContainsRune(requestID, 0) {
+		return fmt.Errorf("request ID contains invalid characters")
+	}
+	return nil
+}
+
+// GetClientRequest returns the record of one of the caller's request IDs, e.g. to check a submission after a timeout
+func (s *SmartContract) GetClientRequest(ctx contractapi.TransactionContextInterface, requestID string) (*ClientRequest, error) {
+	if err := validateRequestID(requestID); err != nil {
+		return nil, err
+	}
+
+	client, err := getCallerIdentity(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := getClientRequest(ctx, client, requestID)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil {
+		return nil, fmt.Errorf("request %s has not been applied", requestID)
+	}
+	return request, nil
+}
+
+// getClientRequest reads the record of a request ID, nil if it was never used
+func getClientRequest(ctx contractapi.TransactionContextInterface, client *clientIdentity, requestID string) (*ClientRequest, error) {
+	key, err := clientRequestKey(ctx, client, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	requestJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read client request: %v", err)
+	}
+	if requestJSON == nil {
+		return nil, nil
+	}
+
+	var request ClientRequest
+	if err := json.Unmarshal(requestJSON, &request); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal client request: %v", err)
+	}
+	return &request, nil
+}
+
+// claimClientRequest records the transient request ID of the caller for function and args.
+// It reports a duplicate if the same request was already applied, the caller then returns without changes;
+// reusing an ID for a different request is an error. Without a request ID nothing is recorded.
+// Sensitive arguments such as descriptions must not be passed, the fingerprint is public.
+func claimClientRequest(ctx contractapi.TransactionContextInterface, function string, args ...string) (bool, error) {
+	transient, err := ctx.GetStub().GetTransient()
+	if err != nil {
+		return false, fmt.Errorf("failed to read transient data: %v", err)
+	}
+	requestIDBytes, found := transient["requestId"]
+	if !found {
+		return false, nil
+	}
+	requestID := string(requestIDBytes)
+	if err := validateRequestID(requestID); err != nil {
+		return false, err
+	}
+
+	client, err := getCallerIdentity(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	hash := sha256.Sum256([]byte(strings.Join(append([]string{function}, args...), "\x00")))
+	fingerprint := hex.EncodeToString(hash[:])
+
+	existing, err := getClientRequest(ctx, client, requestID)
+	if err != nil {
+		return false, err
+	}
+	if existing != nil {
+		if existing.Fingerprint != fingerprint {
+			return false, fmt.Errorf("request ID %s was already used for a different request", requestID)
+		}
+		return true, nil
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return false, err
+	}
+	request := ClientRequest{
+		DocType:     "clientRequest",
+		RequestID:   requestID,
+		MSPID:       client.MSPID,
+		ClientID:    client.CN,
+		Function:    function,
+		Fingerprint: fingerprint,
+		TxID:        ctx.GetStub().GetTxID(),
+		Timestamp:   now.Format(time.RFC3339),
+	}
+
+	requestJSON, err := json.Marshal(request)
+	if err != nil {
+		return false, err
+	}
+	key, err := clientRequestKey(ctx, client, requestID)
+	if err != nil {
+		return false, err
+	}
+	if err := ctx.GetStub().PutState(key, requestJSON); err != nil {
+		return false, fmt.Errorf("failed to save client request: %v", err)
+	}
+	return false, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// couchQuery builds a CouchDB query from typed values. Values are JSON-encoded, so an ID can never
+// change the structure of the query, and the builder has no $regex.
+type couchQuery struct {
+	Selector map[string]interface{} `json:"selector"`
+	Sort     []map[string]string    `json:"sort,omitempty"`
+	UseIndex []string               `json:"use_index,omitempty"`
+}
+
+// newCouchQuery starts a query for documents of docType
+func newCouchQuery(docType string) *couchQuery {
+	return &couchQuery{Selector: map[string]interface{}{"docType": docType}}
+}
+
+// eq matches documents whose field equals value
+func (q *couchQuery) eq(field string, value interface{}) *couchQuery {
+	q.Selector[field] = value
+	return q
+}
+
+// exists matches documents that have (or lack) a field
+func (q *couchQuery) exists(field string, exists bool) *couchQuery {
+	q.Selector[field] = map[string]interface{}{"$exists": exists}
+	return q
+}
+
+// op adds a condition such as $gte or $lt on field; several conditions on one field are combined
+func (q *couchQuery) op(field string, operator string, value interface{}) *couchQuery {
+	conditions, ok := q.Selector[field].(map[string]interface{})
+	if !ok {
+		conditions = make(map[string]interface{})
+		q.Selector[field] = conditions
+	}
+	conditions[operator] = value
+	return q
+}
+
+// in matches documents whose field equals one of values
+func (q *couchQuery) in(field string, values ...interface{}) *couchQuery {
+	return q.op(field, "$in", values)
+}
+
+// or matches documents that satisfy at least one of the alternative selectors
+func (q *couchQuery) or(alternatives ...map[string]interface{}) *couchQuery {
+	q.Selector["$or"] = alternatives
+	return q
+}
+
+// sortBy orders the result by field, descending if desc; CouchDB needs an index covering the field
+func (q *couchQuery) sortBy(field string, desc bool) *couchQuery {
+	direction := "asc"
+	if desc {
+		direction = "desc"
+	}
+	q.Sort = append(q.Sort, map[string]string{field: direction})
+	return q
+}
+
+// useIndex pins the query to an index shipped in META-INF/statedb/couchdb/indexes
+func (q *couchQuery) useIndex(designDoc string, index string) *couchQuery {
+	q.UseIndex = []string{designDoc, index}
+	return q
+}
+
+// build returns the query as JSON string
+func (q *couchQuery) build() (string, error) {
+	queryJSON, err := json.Marshal(q)
+	if err != nil {
+		return "", fmt.Errorf("failed to build query: %v", err)
+	}
+	return string(queryJSON), nil
+}
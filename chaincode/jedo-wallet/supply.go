@@ -0,0 +1,318 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SupplyCounter tracks the JEDO minted and burned globally (AgerID empty) or in the wallets of one Ager.
+// Circulating is Minted minus Burned; for an Ager it is its net issuance, payments to other Agers do not change it.
+type SupplyCounter struct {
+	DocType     string `json:"docType"`
+	AgerID      string `json:"agerId,omitempty"`
+	Minted      int64  `json:"minted"`      // Minor units
+	Burned      int64  `json:"burned"`      // Minor units
+	Circulating int64  `json:"circulating"` // Minor units
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// SupplyReconciliation is the report of ReconcileSupply that operators publish as "Proof of Reconciliation".
+// It is part of the endorsed transaction TxID, ReportHash lets anyone check a published copy against the block.
+type SupplyReconciliation struct {
+	TxID        string                      `json:"txId"`
+	Timestamp   string                      `json:"timestamp"`
+	PageSize    int32                       `json:"pageSize"`
+	Pages       int                         `json:"pages"`
+	WalletCount int                         `json:"walletCount"`
+	Balances    string                      `json:"balances"`    // Sum of all wallet balances
+	Circulating string                      `json:"circulating"` // Global counter
+	Difference  string                      `json:"difference"`  // Balances minus circulating, 0 if reconciled
+	Reconciled  bool                        `json:"reconciled"`
+	Agers       []*AgerSupplyReconciliation `json:"agers"`
+	ReportHash  string                      `json:"reportHash"` // SHA-256 of the report with an empty reportHash
+}
+
+// AgerSupplyReconciliation compares the wallets of an Ager with its counter.
+// NetInflow is the balance received from other Agers and treasuries, it is not a discrepancy.
+type AgerSupplyReconciliation struct {
+	AgerID      string `json:"agerId"`
+	Balances    string `json:"balances"`
+	Circulating string `json:"circulating"`
+	NetInflow   string `json:"netInflow"`
+}
+
+// supplyKey returns the state key of the global counter (agerID empty) or of an Ager's counter
+func supplyKey(ctx contractapi.TransactionContextInterface, agerID string) (string, error) {
+	attributes := []string{"global"}
+	if agerID != "" {
+		attributes = []string{levelAger, agerID}
+	}
+	key, err := ctx.GetStub().CreateCompositeKey("supply", attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// GetSupply returns the supply counter of an Ager, or the global one for an empty agerID (public)
+func (s *SmartContract) GetSupply(ctx contractapi.TransactionContextInterface, agerID string) (*SupplyCounter, error) {
+	if agerID != "" {
+		if err := validateUnitID("ager", agerID); err != nil {
+			return nil, err
+		}
+	}
+
+	counter, _, err := getSupplyCounter(ctx, agerID)
+	return counter, err
+}
+
+// getSupplyCounter reads a supply counter and reports whether it exists; a missing counter is zero
+func getSupplyCounter(ctx contractapi.TransactionContextInterface, agerID string) (*SupplyCounter, bool, error) {
+	key, err := supplyKey(ctx, agerID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	counterJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read supply counter: %v", err)
+	}
+	if counterJSON == nil {
+		return &SupplyCounter{DocType: "supply", AgerID: agerID}, false, nil
+	}
+
+	var counter SupplyCounter
+	if err := json.Unmarshal(counterJSON, &counter); err != nil {
+		return nil, false, fmt.Errorf("failed to unmarshal supply counter: %v", err)
+	}
+	return &counter, true, nil
+}
+
+// putSupplyCounter writes a supply counter
+func putSupplyCounter(ctx contractapi.TransactionContextInterface, counter *SupplyCounter) error {
+	key, err := supplyKey(ctx, counter.AgerID)
+	if err != nil {
+		return err
+	}
+
+	counterJSON, err := json.Marshal(counter)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, counterJSON); err != nil {
+		return fmt.Errorf("failed to save supply counter: %v", err)
+	}
+	return nil
+}
+
+// recordSupplyChange adds minted and burned amounts of a wallet to the global counter and to the counter of its Ager.
+// It fails until MigrateSupplyCounters has created the global counter; an Ager without wallets so far starts at zero.
+// Each counter may be changed only once per transaction, GetState does not see earlier writes of the same transaction.
+func recordSupplyChange(ctx contractapi.TransactionContextInterface, wallet *Wallet, minted int64, burned int64) error {
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	scopes := []string{""}
+	if agerID := walletAger(wallet); agerID != "" {
+		scopes = append(scopes, agerID)
+	}
+
+	for _, agerID := range scopes {
+		counter, found, err := getSupplyCounter(ctx, agerID)
+		if err != nil {
+			return err
+		}
+		// Counting from zero would leave the existing balances out for good
+		if !found && agerID == "" {
+			return fmt.Errorf("supply counters are not initialized, run MigrateSupplyCounters first")
+		}
+		if counter.Minted, err = addAmounts(counter.Minted, minted); err != nil {
+			return err
+		}
+		if counter.Burned, err = addAmounts(counter.Burned, burned); err != nil {
+			return err
+		}
+		counter.Circulating = counter.Minted - counter.Burned
+		counter.UpdatedAt = now.Format(time.RFC3339)
+
+		if err := putSupplyCounter(ctx, counter); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mintToWallet credits newly issued JEDO to a wallet and counts it as minted; every mint path goes through here
+func (s *SmartContract) mintToWallet(ctx contractapi.TransactionContextInterface, wallet *Wallet, amount int64, txType string, counterparty string, description string) error {
+	if err := s.creditWallet(ctx, wallet, amount, txType, counterparty, description); err != nil {
+		return err
+	}
+	return recordSupplyChange(ctx, wallet, amount, 0)
+}
+
+// burnFromWallet removes JEDO from a wallet and counts it as burned; every burn path goes through here
+func (s *SmartContract) burnFromWallet(ctx contractapi.TransactionContextInterface, wallet *Wallet, amount int64, txType string, counterparty string, description string) error {
+	if err := s.debitWallet(ctx, wallet, amount, txType, counterparty, description); err != nil {
+		return err
+	}
+	return recordSupplyChange(ctx, wallet, 0, amount)
+}
+
+// ReconcileSupply re-sums all wallet balances page by page and compares them with the supply counters (admin only).
+// Submit it to emit "SupplyReconciled" or, on a mismatch of the global counter, "SupplyDiscrepancy" with the report.
+// The transaction reads with pagination and therefore writes nothing to the state.
+func (s *SmartContract) ReconcileSupply(ctx contractapi.TransactionContextInterface, pageSize int32) (*SupplyReconciliation, error) {
+	// Admin check
+	if !isAdmin(ctx) {
+		return nil, fmt.Errorf("only admin can reconcile the supply")
+	}
+	if err := validatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	queryString, err := newCouchQuery("wallet").build()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &SupplyReconciliation{TxID: ctx.GetStub().GetTxID(), PageSize: pageSize, Agers: []*AgerSupplyReconciliation{}}
+	var total int64
+	agerBalances := make(map[string]int64)
+
+	bookmark := ""
+	for {
+		fetched, nextBookmark, err := sumWalletPage(ctx, queryString, pageSize, bookmark, &total, agerBalances)
+		if err != nil {
+			return nil, err
+		}
+		report.Pages++
+		report.WalletCount += int(fetched)
+		if fetched < pageSize || nextBookmark == "" {
+			break
+		}
+		bookmark = nextBookmark
+	}
+
+	global, _, err := getSupplyCounter(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	counters, err := getAgerSupplyCounters(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for agerID := range counters {
+		if _, found := agerBalances[agerID]; !found {
+			agerBalances[agerID] = 0
+		}
+	}
+
+	agerIDs := make([]string, 0, len(agerBalances))
+	for agerID := range agerBalances {
+		agerIDs = append(agerIDs, agerID)
+	}
+	sort.Strings(agerIDs)
+	for _, agerID := range agerIDs {
+		var circulating int64
+		if counter, found := counters[agerID]; found {
+			circulating = counter.Circulating
+		}
+		report.Agers = append(report.Agers, &AgerSupplyReconciliation{
+			AgerID:      agerID,
+			Balances:    formatAmount(agerBalances[agerID]),
+			Circulating: formatAmount(circulating),
+			NetInflow:   formatAmount(agerBalances[agerID] - circulating),
+		})
+	}
+
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.Timestamp = now.Format(time.RFC3339)
+	report.Balances = formatAmount(total)
+	report.Circulating = formatAmount(global.Circulating)
+	report.Difference = formatAmount(total - global.Circulating)
+	report.Reconciled = total == global.Circulating
+
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(reportJSON)
+	report.ReportHash = hex.EncodeToString(hash[:])
+
+	eventName := "SupplyReconciled"
+	if !report.Reconciled {
+		eventName = "SupplyDiscrepancy"
+	}
+	eventJSON, _ := json.Marshal(report)
+	_ = ctx.GetStub().SetEvent(eventName, eventJSON)
+
+	return report, nil
+}
+
+// sumWalletPage adds the balances of one page of wallets to total and to the sums per Ager
+func sumWalletPage(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string, total *int64, agerBalances map[string]int64) (int32, string, error) {
+	resultsIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to query wallets: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, "", err
+		}
+
+		var wallet Wallet
+		if err := json.Unmarshal(queryResponse.Value, &wallet); err != nil {
+			return 0, "", fmt.Errorf("failed to unmarshal wallet %s: %v", queryResponse.Key, err)
+		}
+
+		if *total, err = addAmounts(*total, wallet.Balance); err != nil {
+			return 0, "", err
+		}
+		if agerID := walletAger(&wallet); agerID != "" {
+			if agerBalances[agerID], err = addAmounts(agerBalances[agerID], wallet.Balance); err != nil {
+				return 0, "", err
+			}
+		}
+	}
+
+	nextBookmark, fetched := pageMetadata(metadata)
+	return fetched, nextBookmark, nil
+}
+
+// getAgerSupplyCounters returns the supply counters of all Agers by Ager ID
+func getAgerSupplyCounters(ctx contractapi.TransactionContextInterface) (map[string]*SupplyCounter, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("supply", []string{levelAger})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read supply counters: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	counters := make(map[string]*SupplyCounter)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var counter SupplyCounter
+		if err := json.Unmarshal(queryResponse.Value, &counter); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal supply counter: %v", err)
+		}
+		counters[counter.AgerID] = &counter
+	}
+	return counters, nil
+}
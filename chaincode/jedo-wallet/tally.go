@@ -0,0 +1,279 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Chambers of the two-chamber system
+const (
+	chamberHuman  = "human"
+	chamberAger   = "ager"
+	chamberRegnum = "regnum"
+)
+
+// ChamberResult is the outcome of one chamber. In the human chamber Yes/No count votes,
+// in the ager and regnum chambers they count units that did or did not pass internally.
+type ChamberResult struct {
+	Chamber string `json:"chamber"` // human, ager, regnum
+	Yes     int    `json:"yes"`
+	No      int    `json:"no"`
+	Passed  bool   `json:"passed"`
+}
+
+// UnitResult is the internal sub-result of one Ager or Regnum
+type UnitResult struct {
+	Level  string `json:"level"` // ager, regnum
+	UnitID string `json:"unitId"`
+	Regnum string `json:"regnumId,omitempty"` // Regnum of an Ager
+	Yes    int    `json:"yes"`                // Human votes within the unit
+	No     int    `json:"no"`
+	Passed bool   `json:"passed"`
+}
+
+// voteCount holds yes and no votes
+type voteCount struct {
+	yes int
+	no  int
+}
+
+// tallyVotes computes the chamber and unit results of a round and whether the vote passed.
+// Ager votes have one human chamber. Regnum votes add an Ager chamber, Orbis votes a Regnum chamber
+// counting the Regnums whose own two chambers passed. Units without any vote count as not passed.
+func tallyVotes(votes []*ProposalVote, level string, quorum int, units map[string][]string) ([]ChamberResult, []UnitResult, bool) {
+	var humans voteCount
+	agers := make(map[string]*voteCount)
+	regnums := make(map[string]*voteCount)
+	for regnum, agerIDs := range units {
+		regnums[regnum] = &voteCount{}
+		for _, agerID := range agerIDs {
+			agers[regnum+"/"+agerID] = &voteCount{}
+		}
+	}
+
+	for _, vote := range votes {
+		path, err := parseOwnerPath(vote.VoterID, levelHuman)
+		if err != nil {
+			continue
+		}
+
+		counts := []*voteCount{&humans}
+		if level != levelAger {
+			agerKey := path.Regnum + "/" + path.Ager
+			if agers[agerKey] == nil {
+				agers[agerKey] = &voteCount{}
+			}
+			if regnums[path.Regnum] == nil {
+				regnums[path.Regnum] = &voteCount{}
+			}
+			counts = append(counts, agers[agerKey], regnums[path.Regnum])
+		}
+		for _, count := range counts {
+			if vote.Choice == "yes" {
+				count.yes++
+			} else {
+				count.no++
+			}
+		}
+	}
+
+	humanChamber := ChamberResult{Chamber: chamberHuman, Yes: humans.yes, No: humans.no}
+	humanChamber.Passed = quorumReached(humans.yes, humans.no, quorum)
+	if level == levelAger {
+		return []ChamberResult{humanChamber}, []UnitResult{}, humanChamber.Passed
+	}
+
+	// Per-Ager sub-results, and the Ager chamber of each Regnum
+	unitResults := []UnitResult{}
+	agerChambers := make(map[string]*voteCount)
+	agerKeys := make([]string, 0, len(agers))
+	for key := range agers {
+		agerKeys = append(agerKeys, key)
+	}
+	sort.Strings(agerKeys)
+	for _, key := range agerKeys {
+		regnum, agerID, _ := strings.Cut(key, "/")
+		count := agers[key]
+		passed := quorumReached(count.yes, count.no, quorum)
+		unitResults = append(unitResults, UnitResult{Level: levelAger, UnitID: agerID, Regnum: regnum, Yes: count.yes, No: count.no, Passed: passed})
+
+		if agerChambers[regnum] == nil {
+			agerChambers[regnum] = &voteCount{}
+		}
+		if passed {
+			agerChambers[regnum].yes++
+		} else {
+			agerChambers[regnum].no++
+		}
+	}
+
+	if level == levelRegnum {
+		var agerChamber voteCount
+		for _, count := range agerChambers {
+			agerChamber.yes += count.yes
+			agerChamber.no += count.no
+		}
+		chamber := ChamberResult{Chamber: chamberAger, Yes: agerChamber.yes, No: agerChamber.no}
+		chamber.Passed = quorumReached(agerChamber.yes, agerChamber.no, quorum)
+		return []ChamberResult{chamber, humanChamber}, unitResults, chamber.Passed && humanChamber.Passed
+	}
+
+	// Orbis: a Regnum passes internally when its Ager chamber and its Human chamber both pass
+	regnumIDs := make([]string, 0, len(regnums))
+	for regnum := range regnums {
+		regnumIDs = append(regnumIDs, regnum)
+	}
+	sort.Strings(regnumIDs)
+
+	var regnumChamber voteCount
+	for _, regnum := range regnumIDs {
+		count := regnums[regnum]
+		passed := quorumReached(count.yes, count.no, quorum)
+		if agerChamber := agerChambers[regnum]; agerChamber == nil || !quorumReached(agerChamber.yes, agerChamber.no, quorum) {
+			passed = false
+		}
+		unitResults = append(unitResults, UnitResult{Level: levelRegnum, UnitID: regnum, Yes: count.yes, No: count.no, Passed: passed})
+
+		if passed {
+			regnumChamber.yes++
+		} else {
+			regnumChamber.no++
+		}
+	}
+
+	chamber := ChamberResult{Chamber: chamberRegnum, Yes: regnumChamber.yes, No: regnumChamber.no}
+	chamber.Passed = quorumReached(regnumChamber.yes, regnumChamber.no, quorum)
+	return []ChamberResult{chamber, humanChamber}, unitResults, chamber.Passed && humanChamber.Passed
+}
+
+// MemberUnit records that an Ager of a Regnum has members, so a tally reads the chamber units of its scope
+// instead of the membership of every human on the channel
+type MemberUnit struct {
+	DocType string `json:"docType"`
+	Regnum  string `json:"regnum"`
+	AgerID  string `json:"agerId"`
+	Orbis   string `json:"orbis"`
+}
+
+// memberUnitKey returns the state key of the member unit of an Ager, ordered by Regnum
+func memberUnitKey(ctx contractapi.TransactionContextInterface, regnum string, agerID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("memberUnit", []string{regnum, agerID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// addMemberUnit records the Ager of a member path if it has no members yet.
+func addMemberUnit(ctx contractapi.TransactionContextInterface, path ownerPath) error {
+	key, err := memberUnitKey(ctx, path.Regnum, path.Ager)
+	if err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return fmt.Errorf("failed to read member unit: %v", err)
+	}
+	if existing != nil {
+		return nil
+	}
+
+	unitJSON, err := json.Marshal(MemberUnit{DocType: "memberUnit", Regnum: path.Regnum, AgerID: path.Ager, Orbis: path.Orbis})
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, unitJSON); err != nil {
+		return fmt.Errorf("failed to save member unit: %v", err)
+	}
+	return nil
+}
+
+// getChamberUnits returns the Agers per Regnum that have members within the scope of a vote, as recorded in the member units.
+// A Regnum vote reads only the units of its Regnum; an Orbis vote reads one unit per Ager, never the humans.
+func getChamberUnits(ctx contractapi.TransactionContextInterface, level string, unitID string) (map[string][]string, error) {
+	units := make(map[string][]string)
+	if level == levelAger {
+		return units, nil
+	}
+
+	attributes := []string{}
+	if level == levelRegnum {
+		attributes = []string{unitID}
+	}
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("memberUnit", attributes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get member units: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var unit MemberUnit
+		if err := json.Unmarshal(queryResponse.Value, &unit); err != nil {
+			return nil, err
+		}
+		if level == levelOrbis && unit.Orbis != unitID {
+			continue
+		}
+		units[unit.Regnum] = append(units[unit.Regnum], unit.AgerID)
+	}
+
+	return units, nil
+}
+
+// tallyRound computes the result of one round of a proposal from the recorded votes
+func tallyRound(ctx contractapi.TransactionContextInterface, proposal *Proposal, round int) (*ProposalRound, error) {
+	votes, err := getProposalVotes(ctx, proposal.ProposalID, round)
+	if err != nil {
+		return nil, err
+	}
+
+	units, err := getChamberUnits(ctx, proposal.Level, proposal.UnitID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProposalRound{
+		Round:  round,
+		Quorum: roundQuorums[round-1],
+	}
+	for _, vote := range votes {
+		if vote.Choice == "yes" {
+			result.Yes++
+		} else {
+			result.No++
+		}
+	}
+	result.Chambers, result.Units, result.Passed = tallyVotes(votes, proposal.Level, result.Quorum, units)
+
+	return result, nil
+}
+
+// GetProposalTally returns the chamber outcomes, the per-Ager and per-Regnum sub-results and the combined decision of a round.
+// Tallied rounds return the recorded result, the running round a provisional count (public).
+func (s *SmartContract) GetProposalTally(ctx contractapi.TransactionContextInterface, proposalID string, round int) (*ProposalRound, error) {
+	proposal, err := getProposal(ctx, proposalID)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range proposal.Results {
+		if proposal.Results[i].Round == round {
+			return &proposal.Results[i], nil
+		}
+	}
+
+	if proposal.Status != "voting" || round != proposal.Round {
+		return nil, fmt.Errorf("round %d of proposal %s has not taken place", round, proposalID)
+	}
+	return tallyRound(ctx, proposal, round)
+}
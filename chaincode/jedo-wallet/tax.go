@@ -0,0 +1,924 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TaxConfig holds the tax ordinance of one organizational unit
+type TaxConfig struct {
+	DocType          string `json:"docType"`
+	Level            string `json:"level"`            // ager, regnum or orbis
+	UnitID           string `json:"unitId"`           // e.g. alps, ea, jedo.cc
+	Amount           int64  `json:"amount"`           // ager: per-capita tax per human; regnum/orbis: levy split across sub-units (minor units per period)
+	TreasuryWalletID string `json:"treasuryWalletId"` // Wallet receiving the tax
+	UpdatedBy        string `json:"updatedBy"`
+	UpdatedAt        string `json:"updatedAt"`
+}
+
+// TaxEntry records what one payer owed and paid in a settlement
+type TaxEntry struct {
+	Level     string `json:"level"`   // Collecting level (ager: human → ager, regnum: ager → regnum, orbis: regnum → orbis)
+	PayerID   string `json:"payerId"` // Human owner ID, or ager/regnum ID
+	PayeeID   string `json:"payeeId"` // Collecting ager, regnum or orbis ID
+	Due       int64  `json:"due"`
+	Paid      int64  `json:"paid"`
+	Shortfall int64  `json:"shortfall"`
+}
+
+// TaxReport is the auditable result of one SettleTaxes call for an Ager, or of SettleTaxLevies (AgerID empty)
+type TaxReport struct {
+	DocType        string      `json:"docType"`
+	Period         string      `json:"period"`
+	AgerID         string      `json:"agerId,omitempty"`
+	Page           int         `json:"page"` // Number of the SettleTaxes call for the Ager, from 0
+	Entries        []*TaxEntry `json:"entries"`
+	TotalDue       int64       `json:"totalDue"`
+	TotalPaid      int64       `json:"totalPaid"`
+	TotalShortfall int64       `json:"totalShortfall"`
+	Complete       bool        `json:"complete"` // false if SettleTaxes must be called again for the Ager
+	SettledBy      string      `json:"settledBy"`
+	TxID           string      `json:"txId"`
+	Timestamp      string      `json:"timestamp"`
+}
+
+// addEntry adds an entry with its shortfall to the report and its totals
+func (r *TaxReport) addEntry(entry *TaxEntry) {
+	entry.Shortfall = entry.Due - entry.Paid
+	r.Entries = append(r.Entries, entry)
+	r.TotalDue += entry.Due
+	r.TotalPaid += entry.Paid
+	r.TotalShortfall += entry.Shortfall
+}
+
+// AgerTaxSettlement tracks the per-capita tax of one Ager and period across the SettleTaxes calls
+type AgerTaxSettlement struct {
+	DocType        string `json:"docType"`
+	Period         string `json:"period"`
+	AgerID         string `json:"agerId"`
+	RegnumID       string `json:"regnumId"`
+	OrbisID        string `json:"orbisId"`
+	Cursor         string `json:"cursor"`     // Last human settled, humans are settled in ownerId order
+	Pages          int    `json:"pages"`      // Reports written so far (see GetTaxReport)
+	Humans         int    `json:"humans"`     // Humans with a wallet that is not closed, they weigh the levy split
+	FullPayers     int    `json:"fullPayers"` // Humans who paid the tax in full, they share the shortfalls
+	TotalDue       int64  `json:"totalDue"`
+	TotalPaid      int64  `json:"totalPaid"`
+	TotalShortfall int64  `json:"totalShortfall"`
+	Complete       bool   `json:"complete"`
+	UpdatedAt      string `json:"updatedAt"`
+}
+
+// validatePeriod checks that a tax period is a calendar year such as 2026.
+// Taxes are per year only, so two periods never cover the same time.
+func validatePeriod(period string) error {
+	if len(period) != 4 || strings.Trim(period, "0123456789") != "" {
+		return fmt.Errorf("period %q must be a year such as 2026", period)
+	}
+	return nil
+}
+
+// agerTaxSettlementKey returns the state key of the settlement of an Ager in a period
+func agerTaxSettlementKey(ctx contractapi.TransactionContextInterface, period string, agerID string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("taxSettlement", []string{period, agerID})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// taxReportPageKey returns the state key of a report page of an Ager's settlement
+func taxReportPageKey(ctx contractapi.TransactionContextInterface, period string, agerID string, page int) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("taxReportPage", []string{period, agerID, fmt.Sprintf("%06d", page)})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// taxLeviesKey returns the state key of the levy report of a period
+func taxLeviesKey(ctx contractapi.TransactionContextInterface, period string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("taxLevies", []string{period})
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return key, nil
+}
+
+// getTaxDocument reads a tax document by key into doc and reports whether it exists
+func getTaxDocument(ctx contractapi.TransactionContextInterface, key string, doc interface{}) (bool, error) {
+	docJSON, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if docJSON == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(docJSON, doc); err != nil {
+		return false, fmt.Errorf("failed to unmarshal tax document: %v", err)
+	}
+	return true, nil
+}
+
+// putTaxDocument writes a tax document
+func putTaxDocument(ctx contractapi.TransactionContextInterface, key string, doc interface{}) error {
+	docJSON, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(key, docJSON); err != nil {
+		return fmt.Errorf("failed to save tax document: %v", err)
+	}
+	return nil
+}
+
+// getAgerTaxSettlement reads the settlement of an Ager in a period, nil if it has not started
+func getAgerTaxSettlement(ctx contractapi.TransactionContextInterface, period string, agerID string) (*AgerTaxSettlement, error) {
+	key, err := agerTaxSettlementKey(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	var settlement AgerTaxSettlement
+	found, err := getTaxDocument(ctx, key, &settlement)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &settlement, nil
+}
+
+// getTaxConfig reads the tax config of a unit, nil if it has none
+func getTaxConfig(ctx contractapi.TransactionContextInterface, level string, unitID string) (*TaxConfig, error) {
+	key, err := ctx.GetStub().CreateCompositeKey("taxConfig", []string{level, unitID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite key: %v", err)
+	}
+	var config TaxConfig
+	found, err := getTaxDocument(ctx, key, &config)
+	if err != nil || !found {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// GetTaxReport returns a report page of the settlement of an Ager in a period (public, for audits)
+func (s *SmartContract) GetTaxReport(ctx contractapi.TransactionContextInterface, period string, agerID string, page int) (*TaxReport, error) {
+	key, err := taxReportPageKey(ctx, period, agerID, page)
+	if err != nil {
+		return nil, err
+	}
+	var report TaxReport
+	found, err := getTaxDocument(ctx, key, &report)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no tax report page %d of ager %s for period %s", page, agerID, period)
+	}
+	return &report, nil
+}
+
+// GetTaxSettlement returns the progress and totals of the settlement of an Ager in a period (public)
+func (s *SmartContract) GetTaxSettlement(ctx contractapi.TransactionContextInterface, period string, agerID string) (*AgerTaxSettlement, error) {
+	settlement, err := getAgerTaxSettlement(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement == nil {
+		return nil, fmt.Errorf("taxes of ager %s for period %s have not been settled", agerID, period)
+	}
+	return settlement, nil
+}
+
+// GetTaxLevies returns the levy report of a period (public, for audits)
+func (s *SmartContract) GetTaxLevies(ctx contractapi.TransactionContextInterface, period string) (*TaxReport, error) {
+	key, err := taxLeviesKey(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	var report TaxReport
+	found, err := getTaxDocument(ctx, key, &report)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no levy report for period %s", period)
+	}
+	return &report, nil
+}
+
+// SettleTaxes collects the per-capita tax of a period from the humans of one Ager (admin of that Ager only).
+// It settles at most limit humans per call (0 = unlimited) in ownerId order and writes one report page per call;
+// call it again until the report is complete. Run it for every Ager, also those without a tax of their own,
+// the head counts weigh the levies of SettleTaxLevies.
+func (s *SmartContract) SettleTaxes(ctx contractapi.TransactionContextInterface, period string, agerID string, limit int) (*TaxReport, error) {
+	// Admin check, an admin of another Ager's MSP must not debit this Ager's humans
+	if !isAgerAdmin(ctx, agerID) {
+		return nil, fmt.Errorf("only an admin of ager %s can settle its taxes", agerID)
+	}
+
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+	if err := validateUnitID(levelAger, agerID); err != nil {
+		return nil, err
+	}
+	if limit < 0 {
+		return nil, fmt.Errorf("limit must not be negative")
+	}
+
+	leviesKey, err := taxLeviesKey(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	leviesJSON, err := ctx.GetStub().GetState(leviesKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read from world state: %v", err)
+	}
+	if leviesJSON != nil {
+		return nil, fmt.Errorf("the levies of period %s have already been settled", period)
+	}
+
+	settlement, err := getAgerTaxSettlement(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement == nil {
+		settlement = &AgerTaxSettlement{DocType: "taxSettlement", Period: period, AgerID: agerID}
+	} else if settlement.Complete {
+		return nil, fmt.Errorf("taxes of ager %s for period %s have already been settled", agerID, period)
+	}
+
+	// Without a tax of its own the Ager's humans are only counted
+	t := newTaxSettlement(period)
+	config, err := getTaxConfig(ctx, levelAger, agerID)
+	if err != nil {
+		return nil, err
+	}
+	var treasury *Wallet
+	if config != nil && config.Amount > 0 {
+		if treasury, err = s.loadTreasury(ctx, t, config); err != nil {
+			return nil, err
+		}
+	}
+
+	query := newCouchQuery("ownerWallets").eq("agerId", agerID).eq("ownerType", levelHuman).
+		sortBy("docType", false).sortBy("agerId", false).sortBy("ownerType", false).sortBy("ownerId", false).
+		useIndex("indexOwnerWalletsAgerDoc", "indexOwnerWalletsAger")
+	if settlement.Cursor != "" {
+		query.op("ownerId", "$gt", settlement.Cursor)
+	}
+	queryString, err := query.build()
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query humans: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	report := &TaxReport{
+		DocType:  "taxReportPage",
+		Period:   period,
+		AgerID:   agerID,
+		Page:     settlement.Pages,
+		Entries:  []*TaxEntry{},
+		Complete: true,
+	}
+
+	read := 0
+	for resultsIterator.HasNext() {
+		if limit > 0 && read >= limit {
+			report.Complete = false
+			break
+		}
+
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		var list OwnerWallets
+		if err := json.Unmarshal(queryResponse.Value, &list); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal wallets of %s: %v", queryResponse.Key, err)
+		}
+		read++
+		settlement.Cursor = list.OwnerID
+
+		path, err := parseOwnerPath(list.OwnerID, levelHuman)
+		if err != nil {
+			return nil, err
+		}
+		wallets, err := getOwnerWallets(ctx, list.OwnerID)
+		if err != nil {
+			return nil, err
+		}
+		if !t.addHuman(list.OwnerID, wallets) {
+			// Only closed wallets, the human has left
+			continue
+		}
+		settlement.RegnumID = path.Regnum
+		settlement.OrbisID = path.Orbis
+		settlement.Humans++
+		if treasury == nil {
+			continue
+		}
+
+		paid := t.collect(t.humanWallets[list.OwnerID], treasury, config.Amount, "tax", "tax_in")
+		report.addEntry(&TaxEntry{Level: levelAger, PayerID: list.OwnerID, PayeeID: agerID, Due: config.Amount, Paid: paid})
+
+		// Humans who cannot pay are blocked immediately until their arrears are paid
+		if paid < config.Amount {
+			t.block(list.OwnerID)
+			if err := addTaxArrears(ctx, list.OwnerID, agerID, treasury.WalletID, config.Amount-paid, period); err != nil {
+				return nil, err
+			}
+		} else {
+			settlement.FullPayers++
+		}
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.SettledBy = callerID
+	report.TxID = ctx.GetStub().GetTxID()
+	report.Timestamp = now.Format(time.RFC3339)
+
+	if err := t.commit(ctx, report.TxID, report.Timestamp); err != nil {
+		return nil, err
+	}
+
+	settlement.Pages++
+	settlement.TotalDue += report.TotalDue
+	settlement.TotalPaid += report.TotalPaid
+	settlement.TotalShortfall += report.TotalShortfall
+	settlement.Complete = report.Complete
+	settlement.UpdatedAt = report.Timestamp
+
+	pageKey, err := taxReportPageKey(ctx, period, agerID, report.Page)
+	if err != nil {
+		return nil, err
+	}
+	if err := putTaxDocument(ctx, pageKey, report); err != nil {
+		return nil, err
+	}
+	settlementKey, err := agerTaxSettlementKey(ctx, period, agerID)
+	if err != nil {
+		return nil, err
+	}
+	if err := putTaxDocument(ctx, settlementKey, settlement); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"period":         period,
+		"agerId":         agerID,
+		"page":           report.Page,
+		"complete":       report.Complete,
+		"totalDue":       formatAmount(report.TotalDue),
+		"totalPaid":      formatAmount(report.TotalPaid),
+		"totalShortfall": formatAmount(report.TotalShortfall),
+		"timestamp":      report.Timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("TaxesSettled", eventJSON)
+
+	return report, nil
+}
+
+// SettleTaxLevies collects the levies of a period once all Agers are settled (Orbis admin only): every ager pays its share
+// of the regnum levy from its treasury and every regnum its share of the orbis levy.
+// Levies are split 50% equally across the sub-units and 50% by their number of humans, as counted by SettleTaxes.
+// Only treasury wallets are touched, so it runs in one transaction.
+func (s *SmartContract) SettleTaxLevies(ctx contractapi.TransactionContextInterface, period string) (*TaxReport, error) {
+	// Admin check, the levies debit the treasuries of all Agers and Regnums
+	if !isOrbisAdmin(ctx) {
+		return nil, fmt.Errorf("only an Orbis admin can settle levies")
+	}
+
+	if err := validatePeriod(period); err != nil {
+		return nil, err
+	}
+
+	leviesKey, err := taxLeviesKey(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	var existing TaxReport
+	found, err := getTaxDocument(ctx, leviesKey, &existing)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		return nil, fmt.Errorf("the levies of period %s have already been settled", period)
+	}
+
+	configs, err := s.loadTaxConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	settlements, err := getAgerTaxSettlements(ctx, period)
+	if err != nil {
+		return nil, err
+	}
+	for agerID := range configs[levelAger] {
+		if _, found := settlements[agerID]; !found {
+			return nil, fmt.Errorf("taxes of ager %s for period %s have not been settled", agerID, period)
+		}
+	}
+
+	agerIDs := make([]string, 0, len(settlements))
+	for agerID, settlement := range settlements {
+		if !settlement.Complete {
+			return nil, fmt.Errorf("taxes of ager %s for period %s are not settled completely", agerID, period)
+		}
+		agerIDs = append(agerIDs, agerID)
+	}
+	sort.Strings(agerIDs)
+
+	// Hierarchy and head counts of the Agers with humans
+	agerHumans := make(map[string]int)
+	regnumHumans := make(map[string]int)
+	regnumAgers := make(map[string][]string)
+	orbisRegnums := make(map[string][]string)
+	for _, agerID := range agerIDs {
+		settlement := settlements[agerID]
+		if settlement.Humans == 0 {
+			continue
+		}
+		agerHumans[agerID] = settlement.Humans
+		if _, known := regnumAgers[settlement.RegnumID]; !known {
+			orbisRegnums[settlement.OrbisID] = append(orbisRegnums[settlement.OrbisID], settlement.RegnumID)
+		}
+		regnumAgers[settlement.RegnumID] = append(regnumAgers[settlement.RegnumID], agerID)
+		regnumHumans[settlement.RegnumID] += settlement.Humans
+	}
+	for _, regnums := range orbisRegnums {
+		sort.Strings(regnums)
+	}
+
+	t := newTaxSettlement(period)
+	report := &TaxReport{
+		DocType:  "taxLevies",
+		Period:   period,
+		Entries:  []*TaxEntry{},
+		Complete: true,
+	}
+
+	for _, step := range []struct {
+		level    string
+		subLevel string
+		members  map[string][]string
+		humans   map[string]int
+	}{
+		{levelRegnum, levelAger, regnumAgers, agerHumans},
+		{levelOrbis, levelRegnum, orbisRegnums, regnumHumans},
+	} {
+		unitIDs := make([]string, 0, len(configs[step.level]))
+		for unitID := range configs[step.level] {
+			unitIDs = append(unitIDs, unitID)
+		}
+		sort.Strings(unitIDs)
+
+		for _, unitID := range unitIDs {
+			config := configs[step.level][unitID]
+			members := step.members[unitID]
+			if config.Amount == 0 || len(members) == 0 {
+				continue
+			}
+
+			treasury, err := s.loadTreasury(ctx, t, config)
+			if err != nil {
+				return nil, err
+			}
+
+			shares, err := splitLevy(config.Amount, members, step.humans)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, memberID := range members {
+				entry := &TaxEntry{Level: step.level, PayerID: memberID, PayeeID: unitID, Due: shares[memberID]}
+				if memberConfig := configs[step.subLevel][memberID]; memberConfig != nil {
+					memberTreasury, err := s.loadTreasury(ctx, t, memberConfig)
+					if err != nil {
+						return nil, err
+					}
+					entry.Paid = t.collect([]*Wallet{memberTreasury}, treasury, entry.Due, "tax", "tax_in")
+				}
+				report.addEntry(entry)
+			}
+		}
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return nil, err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.SettledBy = callerID
+	report.TxID = ctx.GetStub().GetTxID()
+	report.Timestamp = now.Format(time.RFC3339)
+
+	if err := t.commit(ctx, report.TxID, report.Timestamp); err != nil {
+		return nil, err
+	}
+	if err := putTaxDocument(ctx, leviesKey, report); err != nil {
+		return nil, err
+	}
+
+	// Emit event
+	eventPayload := map[string]interface{}{
+		"period":         period,
+		"totalDue":       formatAmount(report.TotalDue),
+		"totalPaid":      formatAmount(report.TotalPaid),
+		"totalShortfall": formatAmount(report.TotalShortfall),
+		"timestamp":      report.Timestamp,
+	}
+	eventJSON, _ := json.Marshal(eventPayload)
+	_ = ctx.GetStub().SetEvent("TaxLeviesSettled", eventJSON)
+
+	return report, nil
+}
+
+// getAgerTaxSettlements returns the settlements of all Agers in a period by Ager ID
+func getAgerTaxSettlements(ctx contractapi.TransactionContextInterface, period string) (map[string]*AgerTaxSettlement, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("taxSettlement", []string{period})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tax settlements: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	settlements := make(map[string]*AgerTaxSettlement)
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var settlement AgerTaxSettlement
+		if err := json.Unmarshal(queryResponse.Value, &settlement); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tax settlement: %v", err)
+		}
+		settlements[settlement.AgerID] = &settlement
+	}
+	return settlements, nil
+}
+
+// SetTaxConfig sets the tax of an ager (admin of that Ager), or the levy of a regnum or orbis (Orbis admin only).
+// The treasury wallet is created for the unit if it does not exist yet.
+func (s *SmartContract) SetTaxConfig(ctx contractapi.TransactionContextInterface, level string, unitID string, amountStr string, treasuryWalletID string) error {
+	if err := validateLevel(level); err != nil {
+		return err
+	}
+
+	// Admin check, levies are paid by the treasuries of other units
+	if level == levelAger {
+		if !isAgerAdmin(ctx, unitID) {
+			return fmt.Errorf("only an admin of ager %s can set its tax", unitID)
+		}
+	} else if !isOrbisAdmin(ctx) {
+		return fmt.Errorf("only an Orbis admin can set the %s levy", level)
+	}
+
+	if err := validateUnitID(level, unitID); err != nil {
+		return err
+	}
+	if err := validateWalletID(treasuryWalletID); err != nil {
+		return err
+	}
+	amount, err := parseAmount(amountStr)
+	if err != nil {
+		return err
+	}
+
+	// Treasury wallets belong to the unit itself
+	exists, err := s.WalletExists(ctx, treasuryWalletID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		treasury, err := s.GetWallet(ctx, treasuryWalletID)
+		if err != nil {
+			return err
+		}
+		if treasury.OwnerType != level || treasury.OwnerID != unitID {
+			return fmt.Errorf("wallet %s is not the treasury of %s %s", treasuryWalletID, level, unitID)
+		}
+	} else if err := createTreasuryWallet(ctx, treasuryWalletID, level, unitID); err != nil {
+		return err
+	}
+
+	callerID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return err
+	}
+	now, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	config := TaxConfig{
+		DocType:          "taxConfig",
+		Level:            level,
+		UnitID:           unitID,
+		Amount:           amount,
+		TreasuryWalletID: treasuryWalletID,
+		UpdatedBy:        callerID,
+		UpdatedAt:        now.Format(time.RFC3339),
+	}
+
+	configKey, err := ctx.GetStub().CreateCompositeKey("taxConfig", []string{level, unitID})
+	if err != nil {
+		return fmt.Errorf("failed to create composite key: %v", err)
+	}
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(configKey, configJSON)
+}
+
+// GetTaxConfigs returns the tax parameters of all units (public)
+func (s *SmartContract) GetTaxConfigs(ctx contractapi.TransactionContextInterface) ([]*TaxConfig, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey("taxConfig", []string{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tax configs: %v", err)
+	}
+	defer resultsIterator.Close()
+
+	var configs []*TaxConfig
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var config TaxConfig
+		err = json.Unmarshal(queryResponse.Value, &config)
+		if err != nil {
+			return nil, err
+		}
+
+		configs = append(configs, &config)
+	}
+
+	return configs, nil
+}
+
+// loadTaxConfigs returns all tax configs indexed by level and unit ID
+func (s *SmartContract) loadTaxConfigs(ctx contractapi.TransactionContextInterface) (map[string]map[string]*TaxConfig, error) {
+	configList, err := s.GetTaxConfigs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	configs := make(map[string]map[string]*TaxConfig)
+	for _, config := range configList {
+		if configs[config.Level] == nil {
+			configs[config.Level] = make(map[string]*TaxConfig)
+		}
+		configs[config.Level][config.UnitID] = config
+	}
+	return configs, nil
+}
+
+// createTreasuryWallet creates the treasury wallet of an ager, regnum or orbis
+func createTreasuryWallet(ctx contractapi.TransactionContextInterface, walletID string, level string, unitID string) error {
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	now := txTime.Format(time.RFC3339)
+	wallet := Wallet{
+		DocType:       "wallet",
+		WalletID:      walletID,
+		OwnerID:       unitID,
+		OwnerType:     level,
+		Currency:      "JEDO",
+		Status:        "active",
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Metadata:      map[string]string{"treasury": level},
+		SchemaVersion: currentSchemaVersion,
+	}
+
+	if err := putWalletState(ctx, &wallet); err != nil {
+		return err
+	}
+	if _, err := addOwnerWallets(ctx, &wallet); err != nil {
+		return err
+	}
+	return applyWalletEndorsementPolicy(ctx, &wallet)
+}
+
+// splitLevy splits a levy 50% equally across the units and 50% by their number of humans.
+// Rounding remainders go to the units in order, one minor unit each, so the shares add up to the levy.
+func splitLevy(levy int64, units []string, humans map[string]int) (map[string]int64, error) {
+	equalPart := levy / 2
+	humanPart := levy - equalPart
+
+	totalHumans := 0
+	for _, unitID := range units {
+		totalHumans += humans[unitID]
+	}
+
+	shares := make(map[string]int64)
+	var assigned int64
+	for _, unitID := range units {
+		share := equalPart / int64(len(units))
+		if totalHumans > 0 {
+			byHumans, err := mulDiv(humanPart, int64(humans[unitID]), int64(totalHumans))
+			if err != nil {
+				return nil, err
+			}
+			share += byHumans
+		} else {
+			share += humanPart / int64(len(units))
+		}
+		shares[unitID] = share
+		assigned += share
+	}
+
+	for i := 0; assigned < levy; i = (i + 1) % len(units) {
+		shares[units[i]]++
+		assigned++
+	}
+	return shares, nil
+}
+
+// taxSettlement applies tax payments to in-memory wallet copies and aggregates one
+// transaction record per wallet and type, since GetState does not see writes of the same transaction
+type taxSettlement struct {
+	period       string
+	wallets      map[string]*Wallet
+	humanWallets map[string][]*Wallet
+	records      []*Transaction
+	recordIndex  map[string]*Transaction
+	touched      []string
+	touchedSet   map[string]bool
+}
+
+// newTaxSettlement starts the in-memory settlement of a period
+func newTaxSettlement(period string) *taxSettlement {
+	return &taxSettlement{
+		period:       period,
+		wallets:      make(map[string]*Wallet),
+		humanWallets: make(map[string][]*Wallet),
+		recordIndex:  make(map[string]*Transaction),
+		touchedSet:   make(map[string]bool),
+	}
+}
+
+// addHuman adds the wallets of a human that are not closed, in walletId order, and reports whether there are any
+func (t *taxSettlement) addHuman(humanID string, wallets []*Wallet) bool {
+	sort.Slice(wallets, func(i, j int) bool { return wallets[i].WalletID < wallets[j].WalletID })
+	for _, wallet := range wallets {
+		if wallet.Status == "closed" {
+			continue
+		}
+		if known := t.wallets[wallet.WalletID]; known != nil {
+			wallet = known
+		}
+		t.wallets[wallet.WalletID] = wallet
+		t.humanWallets[humanID] = append(t.humanWallets[humanID], wallet)
+	}
+	return len(t.humanWallets[humanID]) > 0
+}
+
+// loadWallet reads a wallet once per settlement, later calls return the in-memory copy
+func (s *SmartContract) loadWallet(ctx contractapi.TransactionContextInterface, t *taxSettlement, walletID string) (*Wallet, error) {
+	if wallet := t.wallets[walletID]; wallet != nil {
+		return wallet, nil
+	}
+	wallet, err := s.GetWallet(ctx, walletID)
+	if err != nil {
+		return nil, err
+	}
+	t.wallets[walletID] = wallet
+	return wallet, nil
+}
+
+// loadTreasury returns the active treasury wallet of a tax config
+func (s *SmartContract) loadTreasury(ctx contractapi.TransactionContextInterface, t *taxSettlement, config *TaxConfig) (*Wallet, error) {
+	wallet, err := s.loadWallet(ctx, t, config.TreasuryWalletID)
+	if err != nil {
+		return nil, fmt.Errorf("treasury wallet %s of %s %s: %v", config.TreasuryWalletID, config.Level, config.UnitID, err)
+	}
+	if wallet.Status != "active" {
+		return nil, fmt.Errorf("treasury wallet %s of %s %s is not active", config.TreasuryWalletID, config.Level, config.UnitID)
+	}
+	return wallet, nil
+}
+
+// collect moves up to due from the active payer wallets to the payee and returns the amount paid
+func (t *taxSettlement) collect(payers []*Wallet, payee *Wallet, due int64, outType string, inType string) int64 {
+	var paid int64
+	for _, payer := range payers {
+		if paid == due {
+			break
+		}
+		if payer.Status != "active" || payer.Balance <= 0 {
+			continue
+		}
+
+		amount := due - paid
+		if payer.Balance < amount {
+			amount = payer.Balance
+		}
+
+		t.post(payer, outType, -amount, payee.WalletID)
+		t.post(payee, inType, amount, payer.WalletID)
+		paid += amount
+	}
+	return paid
+}
+
+// post applies a signed amount to a wallet and adds it to the aggregated record of that wallet and type
+func (t *taxSettlement) post(wallet *Wallet, txType string, amount int64, counterparty string) {
+	t.touch(wallet)
+	wallet.Balance += amount
+
+	key := wallet.WalletID + "~" + txType
+	record := t.recordIndex[key]
+	if record == nil {
+		record = &Transaction{
+			WalletID:     wallet.WalletID,
+			Type:         txType,
+			Counterparty: counterparty,
+			Description:  "Tax " + t.period,
+		}
+		t.recordIndex[key] = record
+		t.records = append(t.records, record)
+	} else if record.Counterparty != counterparty {
+		record.Counterparty = "tax:" + t.period
+	}
+	record.Amount += amount
+	record.Balance = wallet.Balance
+}
+
+// touch marks a wallet to be written on commit
+func (t *taxSettlement) touch(wallet *Wallet) {
+	if !t.touchedSet[wallet.WalletID] {
+		t.touchedSet[wallet.WalletID] = true
+		t.touched = append(t.touched, wallet.WalletID)
+	}
+}
+
+// block sets every active wallet of an insolvent human to blocked
+func (t *taxSettlement) block(humanID string) {
+	for _, wallet := range t.humanWallets[humanID] {
+		if wallet.Status == "active" {
+			wallet.Status = "blocked"
+			t.touch(wallet)
+		}
+	}
+}
+
+// unblock reactivates the blocked wallets of a human whose arrears are cleared
+func (t *taxSettlement) unblock(humanID string) {
+	for _, wallet := range t.humanWallets[humanID] {
+		if wallet.Status == "blocked" {
+			wallet.Status = "active"
+			t.touch(wallet)
+		}
+	}
+}
+
+// commit writes every touched wallet once and the aggregated transaction records
+func (t *taxSettlement) commit(ctx contractapi.TransactionContextInterface, txID string, timestamp string) error {
+	for _, walletID := range t.touched {
+		wallet := t.wallets[walletID]
+		wallet.UpdatedAt = timestamp
+		if err := putWalletState(ctx, wallet); err != nil {
+			return err
+		}
+	}
+
+	for _, record := range t.records {
+		record.TxID = txID
+		record.Timestamp = timestamp
+		if err := putTransaction(ctx, t.wallets[record.WalletID], record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
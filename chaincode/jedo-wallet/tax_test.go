@@ -0,0 +1,49 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLevy(t *testing.T) {
+	tests := []struct {
+		name   string
+		levy   int64
+		units  []string
+		humans map[string]int
+		want   map[string]int64
+	}{
+		{"half equal, half by humans", 1000, []string{"alps", "bern"}, map[string]int{"alps": 3, "bern": 1},
+			map[string]int64{"alps": 625, "bern": 375}},
+		{"remainders to the units in order", 1001, []string{"alps", "bern", "jura"}, map[string]int{"alps": 1, "bern": 1, "jura": 1},
+			map[string]int64{"alps": 334, "bern": 334, "jura": 333}},
+		{"units without humans get the equal half", 10, []string{"alps", "bern", "jura"}, map[string]int{"jura": 5},
+			map[string]int64{"alps": 2, "bern": 2, "jura": 6}},
+		{"no humans at all splits equally", 101, []string{"alps", "bern"}, nil,
+			map[string]int64{"alps": 51, "bern": 50}},
+		{"single unit takes the whole levy", 7, []string{"alps"}, map[string]int{"alps": 2},
+			map[string]int64{"alps": 7}},
+		{"zero levy", 0, []string{"alps", "bern"}, map[string]int{"alps": 1},
+			map[string]int64{"alps": 0, "bern": 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := splitLevy(tt.levy, tt.units, tt.humans)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(shares, tt.want) {
+				t.Errorf("splitLevy(%d) = %v, want %v", tt.levy, shares, tt.want)
+			}
+
+			var total int64
+			for _, share := range shares {
+				total += share
+			}
+			if total != tt.levy {
+				t.Errorf("shares add up to %d, want %d", total, tt.levy)
+			}
+		})
+	}
+}
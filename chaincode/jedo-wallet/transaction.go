@@ -3,31 +3,52 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
-// Transfer transfers funds from one wallet to another
-func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fromWalletID string, toWalletID string, amount float64, description string) error {
-	// Check caller is Human
+// Transfer transfers funds from one wallet to another (only the owner of the source wallet, human or gens)
+func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fromWalletID string, toWalletID string, amountStr string, description string) error {
+	// Check caller is Human or Gens
 	callerRole, err := getCallerRole(ctx)
 	if err != nil {
 		return err
 	}
 
-	if callerRole != "human" {
-		return fmt.Errorf("only humans can transfer tokens")
+	if callerRole != "human" && callerRole != "gens" {
+		return fmt.Errorf("only humans and gens can transfer tokens")
+	}
+
+	// Both sides would be written from the same stale balance
+	if fromWalletID == toWalletID {
+		return fmt.Errorf("cannot transfer to the source wallet")
 	}
 
 	// Validate amount
+	amount, err := parseAmount(amountStr)
+	if err != nil {
+		return err
+	}
 	if amount <= 0 {
 		return fmt.Errorf("transfer amount must be positive")
 	}
 
-	// Get caller ID for ownership verification
-	callerID, err := ctx.GetClientIdentity().GetID()
+	// A retry with the same client request ID is not applied twice
+	duplicate, err := claimClientRequest(ctx, "Transfer", fromWalletID, toWalletID, formatAmount(amount))
+	if err != nil || duplicate {
+		return err
+	}
+
+	// The description may come as transient data so it never appears in the proposal
+	description, err = getSensitiveArg(ctx, "description", description)
+	if err != nil {
+		return err
+	}
+
+	// Get caller identity for ownership verification
+	caller, err := getCallerIdentity(ctx)
 	if err != nil {
 		return err
 	}
@@ -38,8 +59,8 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fr
 		return fmt.Errorf("source wallet error: %v", err)
 	}
 
-	// Verify caller owns fromWallet (check if callerID contains ownerID)
-	if !strings.Contains(callerID, fromWallet.OwnerID) {
+	// Verify caller owns fromWallet
+	if !caller.isOwner(fromWallet.OwnerID, fromWallet.OwnerType) {
 		return fmt.Errorf("you can only transfer from your own wallet")
 	}
 
@@ -54,17 +75,28 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fr
 		return fmt.Errorf("source wallet %s is not active (status: %s)", fromWalletID, fromWallet.Status)
 	}
 
-	if toWallet.Status != "active" {
-		return fmt.Errorf("destination wallet %s is not active (status: %s)", toWalletID, toWallet.Status)
+	if err := checkTransferDestination(ctx, fromWallet, toWallet); err != nil {
+		return err
 	}
 
 	// Check sufficient balance
 	if fromWallet.Balance < amount {
-		return fmt.Errorf("insufficient balance: wallet %s has %.2f but transfer requires %.2f", fromWalletID, fromWallet.Balance, amount)
+		return fmt.Errorf("insufficient balance: wallet %s has %s but transfer requires %s", fromWalletID, formatAmount(fromWallet.Balance), formatAmount(amount))
+	}
+
+	// Enforce holding cap of the receiving owner (moving funds between own wallets changes nothing)
+	if fromWallet.OwnerID != toWallet.OwnerID {
+		if err := s.checkHoldingCap(ctx, toWallet, amount); err != nil {
+			return err
+		}
 	}
 
-	// Perform transfer
-	now := time.Now().UTC().Format(time.RFC3339)
+	// Perform transfer at the transaction time, which is the same on every endorsing peer
+	txTime, err := getTxTime(ctx)
+	if err != nil {
+		return err
+	}
+	now := txTime.Format(time.RFC3339)
 	txID := ctx.GetStub().GetTxID()
 
 	// Debit from source
@@ -76,29 +108,15 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fr
 	toWallet.UpdatedAt = now
 
 	// Save updated wallets
-	fromWalletJSON, err := json.Marshal(fromWallet)
-	if err != nil {
+	if err := putWalletState(ctx, fromWallet); err != nil {
 		return err
 	}
-
-	toWalletJSON, err := json.Marshal(toWallet)
-	if err != nil {
+	if err := putWalletState(ctx, toWallet); err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(fromWalletID, fromWalletJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update source wallet: %v", err)
-	}
-
-	err = ctx.GetStub().PutState(toWalletID, toWalletJSON)
-	if err != nil {
-		return fmt.Errorf("failed to update destination wallet: %v", err)
-	}
-
 	// Record debit transaction
 	debitTx := Transaction{
-		DocType:      "transaction",
 		TxID:         txID,
 		WalletID:     fromWalletID,
 		Type:         "transfer_out",
@@ -109,24 +127,13 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fr
 		Timestamp:    now,
 	}
 
-	debitKey, err := ctx.GetStub().CreateCompositeKey("transaction", []string{fromWalletID, txID})
-	if err != nil {
-		return err
-	}
-
-	debitJSON, err := json.Marshal(debitTx)
-	if err != nil {
-		return err
-	}
-
-	err = ctx.GetStub().PutState(debitKey, debitJSON)
+	err = putTransaction(ctx, fromWallet, &debitTx)
 	if err != nil {
 		return err
 	}
 
 	// Record credit transaction
 	creditTx := Transaction{
-		DocType:      "transaction",
 		TxID:         txID,
 		WalletID:     toWalletID,
 		Type:         "transfer_in",
@@ -137,19 +144,16 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fr
 		Timestamp:    now,
 	}
 
-	creditKey, err := ctx.GetStub().CreateCompositeKey("transaction", []string{toWalletID, txID})
+	err = putTransaction(ctx, toWallet, &creditTx)
 	if err != nil {
 		return err
 	}
 
-	creditJSON, err := json.Marshal(creditTx)
-	if err != nil {
-		return err
-	}
-
-	err = ctx.GetStub().PutState(creditKey, creditJSON)
-	if err != nil {
-		return err
+	// Count the transfer for the voting activity of both owners, moving funds between own wallets does not count
+	if fromWallet.OwnerID != toWallet.OwnerID {
+		if err := recordTransferActivity(ctx, fromWallet, toWallet); err != nil {
+			return err
+		}
 	}
 
 	// Emit event
@@ -157,9 +161,9 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fr
 		"txId":         txID,
 		"fromWalletId": fromWalletID,
 		"toWalletId":   toWalletID,
-		"amount":       amount,
-		"fromBalance":  fromWallet.Balance,
-		"toBalance":    toWallet.Balance,
+		"amount":       formatAmount(amount),
+		"fromBalance":  formatAmount(fromWallet.Balance),
+		"toBalance":    formatAmount(toWallet.Balance),
 		"timestamp":    now,
 	}
 
@@ -168,120 +172,183 @@ func (s *SmartContract) Transfer(ctx contractapi.TransactionContextInterface, fr
 	return nil
 }
 
-// Credit adds funds to a wallet (admin only - for minting)
-func (s *SmartContract) Credit(ctx contractapi.TransactionContextInterface, walletID string, amount float64, description string) error {
+// Credit adds funds to a wallet (admin only, bootstrap mode only - for minting)
+func (s *SmartContract) Credit(ctx contractapi.TransactionContextInterface, walletID string, amountStr string, description string) error {
 	// Admin check
 	if !isAdmin(ctx) {
 		return fmt.Errorf("only admin can credit wallets")
 	}
+	if err := s.requireBootstrapMode(ctx, "credit"); err != nil {
+		return err
+	}
 
+	amount, err := parseAmount(amountStr)
+	if err != nil {
+		return err
+	}
 	if amount <= 0 {
 		return fmt.Errorf("credit amount must be positive")
 	}
 
-	wallet, err := s.GetWallet(ctx, walletID)
-	if err != nil {
+	// A retry with the same client request ID is not applied twice
+	duplicate, err := claimClientRequest(ctx, "Credit", walletID, formatAmount(amount))
+	if err != nil || duplicate {
 		return err
 	}
 
-	if wallet.Status != "active" {
-		return fmt.Errorf("wallet %s is not active", walletID)
+	description, err = getSensitiveArg(ctx, "description", description)
+	if err != nil {
+		return err
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	wallet.Balance += amount
-	wallet.UpdatedAt = now
-
-	walletJSON, err := json.Marshal(wallet)
+	wallet, err := s.GetWallet(ctx, walletID)
 	if err != nil {
 		return err
 	}
 
-	err = ctx.GetStub().PutState(walletID, walletJSON)
+	return s.mintToWallet(ctx, wallet, amount, "credit", "", description)
+}
+
+// Debit removes funds from a wallet (admin only - for burning)
+func (s *SmartContract) Debit(ctx contractapi.TransactionContextInterface, walletID string, amountStr string, description string) error {
+	// Admin check
+	if !isAdmin(ctx) {
+		return fmt.Errorf("only admin can debit wallets")
+	}
+
+	amount, err := parseAmount(amountStr)
 	if err != nil {
 		return err
 	}
+	if amount <= 0 {
+		return fmt.Errorf("debit amount must be positive")
+	}
 
-	// Record transaction
-	tx := Transaction{
-		DocType:     "transaction",
-		TxID:        ctx.GetStub().GetTxID(),
-		WalletID:    walletID,
-		Type:        "credit",
-		Amount:      amount,
-		Balance:     wallet.Balance,
-		Description: description,
-		Timestamp:   now,
+	// A retry with the same client request ID is not applied twice
+	duplicate, err := claimClientRequest(ctx, "Debit", walletID, formatAmount(amount))
+	if err != nil || duplicate {
+		return err
 	}
 
-	txKey, err := ctx.GetStub().CreateCompositeKey("transaction", []string{walletID, ctx.GetStub().GetTxID()})
+	description, err = getSensitiveArg(ctx, "description", description)
 	if err != nil {
 		return err
 	}
 
-	txJSON, err := json.Marshal(tx)
+	wallet, err := s.GetWallet(ctx, walletID)
 	if err != nil {
 		return err
 	}
 
-	return ctx.GetStub().PutState(txKey, txJSON)
+	return s.burnFromWallet(ctx, wallet, amount, "debit", "", description)
 }
 
-// Debit removes funds from a wallet (admin only - for burning)
-func (s *SmartContract) Debit(ctx contractapi.TransactionContextInterface, walletID string, amount float64, description string) error {
-	// Admin check
-	if !isAdmin(ctx) {
-		return fmt.Errorf("only admin can debit wallets")
+// creditWallet adds amount to an active or blocked wallet within the holding cap and records a transaction of txType
+func (s *SmartContract) creditWallet(ctx contractapi.TransactionContextInterface, wallet *Wallet, amount int64, txType string, counterparty string, description string) error {
+	if wallet.Status != "active" && wallet.Status != "blocked" {
+		return fmt.Errorf("wallet %s is not active", wallet.WalletID)
 	}
 
-	if amount <= 0 {
-		return fmt.Errorf("debit amount must be positive")
+	if err := s.checkHoldingCap(ctx, wallet, amount); err != nil {
+		return err
 	}
 
-	wallet, err := s.GetWallet(ctx, walletID)
+	balance, err := addAmounts(wallet.Balance, amount)
+	if err != nil {
+		return err
+	}
+
+	txTime, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
+	now := txTime.Format(time.RFC3339)
+	wallet.Balance = balance
+	wallet.UpdatedAt = now
+
+	if err := putWalletState(ctx, wallet); err != nil {
+		return err
+	}
+
+	// Record transaction
+	tx := Transaction{
+		TxID:         ctx.GetStub().GetTxID(),
+		WalletID:     wallet.WalletID,
+		Type:         txType,
+		Amount:       amount,
+		Balance:      wallet.Balance,
+		Counterparty: counterparty,
+		Description:  description,
+		Timestamp:    now,
+	}
 
+	return putTransaction(ctx, wallet, &tx)
+}
+
+// debitWallet removes amount from an active wallet and records a transaction of txType
+func (s *SmartContract) debitWallet(ctx contractapi.TransactionContextInterface, wallet *Wallet, amount int64, txType string, counterparty string, description string) error {
 	if wallet.Status != "active" {
-		return fmt.Errorf("wallet %s is not active", walletID)
+		return fmt.Errorf("wallet %s is not active", wallet.WalletID)
 	}
 
 	if wallet.Balance < amount {
 		return fmt.Errorf("insufficient balance")
 	}
 
-	now := time.Now().UTC().Format(time.RFC3339)
-	wallet.Balance -= amount
-	wallet.UpdatedAt = now
-
-	walletJSON, err := json.Marshal(wallet)
+	txTime, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
+	now := txTime.Format(time.RFC3339)
+	wallet.Balance -= amount
+	wallet.UpdatedAt = now
 
-	err = ctx.GetStub().PutState(walletID, walletJSON)
-	if err != nil {
+	if err := putWalletState(ctx, wallet); err != nil {
 		return err
 	}
 
 	// Record transaction
 	tx := Transaction{
-		DocType:     "transaction",
-		TxID:        ctx.GetStub().GetTxID(),
-		WalletID:    walletID,
-		Type:        "debit",
-		Amount:      -amount,
-		Balance:     wallet.Balance,
-		Description: description,
-		Timestamp:   now,
+		TxID:         ctx.GetStub().GetTxID(),
+		WalletID:     wallet.WalletID,
+		Type:         txType,
+		Amount:       -amount,
+		Balance:      wallet.Balance,
+		Counterparty: counterparty,
+		Description:  description,
+		Timestamp:    now,
 	}
 
-	txKey, err := ctx.GetStub().CreateCompositeKey("transaction", []string{walletID, ctx.GetStub().GetTxID()})
+	return putTransaction(ctx, wallet, &tx)
+}
+
+// checkTransferDestination checks that toWallet may receive a transfer from fromWallet
+func checkTransferDestination(ctx contractapi.TransactionContextInterface, fromWallet *Wallet, toWallet *Wallet) error {
+	// Blocked wallets may still receive funds so their owner can pay tax arrears
+	if toWallet.Status != "active" && toWallet.Status != "blocked" {
+		return fmt.Errorf("destination wallet %s is not active (status: %s)", toWallet.WalletID, toWallet.Status)
+	}
+
+	// Transfers between Regnums can be locked by the security council
+	return checkCrossChannelLock(ctx, fromWallet, toWallet)
+}
+
+// putTransaction stores a transaction record of wallet under the composite key transaction~walletId~txId~type,
+// so a wallet can carry one record per transaction type within the same Fabric transaction (batches add ~index)
+func putTransaction(ctx contractapi.TransactionContextInterface, wallet *Wallet, tx *Transaction) error {
+	tx.DocType = "transaction"
+	tx.SchemaVersion = currentSchemaVersion
+
+	txKey, err := transactionKey(ctx, tx)
 	if err != nil {
 		return err
 	}
 
+	// In private data mode balance and description go to the Ager's collection
+	if err := putTransactionPrivateDetails(ctx, wallet, tx, txKey); err != nil {
+		return err
+	}
+
 	txJSON, err := json.Marshal(tx)
 	if err != nil {
 		return err
@@ -289,3 +356,16 @@ func (s *SmartContract) Debit(ctx contractapi.TransactionContextInterface, walle
 
 	return ctx.GetStub().PutState(txKey, txJSON)
 }
+
+// transactionKey returns the state key of a transaction record; records of a batch also carry their index
+func transactionKey(ctx contractapi.TransactionContextInterface, tx *Transaction) (string, error) {
+	attributes := []string{tx.WalletID, tx.TxID, tx.Type}
+	if tx.Index > 0 {
+		attributes = append(attributes, strconv.Itoa(tx.Index))
+	}
+	txKey, err := ctx.GetStub().CreateCompositeKey("transaction", attributes)
+	if err != nil {
+		return "", fmt.Errorf("failed to create composite key: %v", err)
+	}
+	return txKey, nil
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"time"
 
@@ -33,9 +32,29 @@ func validateOwnerID(ownerID string) error {
 	return nil
 }
 
-// getCurrentTimestamp returns the current UTC timestamp in RFC3339 format
-func getCurrentTimestamp() string {
-	return time.Now().UTC().Format(time.RFC3339)
+// getTxTime returns the transaction timestamp, which is the same on every endorsing peer
+func getTxTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	ts, err := ctx.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %v", err)
+	}
+	return ts.AsTime().UTC(), nil
+}
+
+// validateUnitID validates the ID of an organizational unit (ager, regnum, orbis)
+func validateUnitID(kind string, unitID string) error {
+	if unitID == "" {
+		return fmt.Errorf("%s ID cannot be empty", kind)
+	}
+	if len(unitID) > 64 {
+		return fmt.Errorf("%s ID must not exceed 64 characters", kind)
+	}
+	for _, c := range unitID {
+		if !(c >= 'a' && c <= 'z') && !(c >= '0' && c <= '9') && c != '-' && c != '.' {
+			return fmt.Errorf("%s ID %q may only contain lowercase letters, digits, '-' and '.'", kind, unitID)
+		}
+	}
+	return nil
 }
 
 // DeleteWallet deletes a wallet (admin function, use with caution)
@@ -47,17 +66,16 @@ func (s *SmartContract) DeleteWallet(ctx contractapi.TransactionContextInterface
 
 	// Only allow deletion of wallets with zero balance
 	if wallet.Balance != 0 {
-		return fmt.Errorf("cannot delete wallet with non-zero balance (current: %.2f)", wallet.Balance)
+		return fmt.Errorf("cannot delete wallet with non-zero balance (current: %s)", formatAmount(wallet.Balance))
 	}
 
 	// Mark as closed instead of deleting
-	wallet.Status = "closed"
-	wallet.UpdatedAt = getCurrentTimestamp()
-
-	walletJSON, err := json.Marshal(wallet)
+	now, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
+	wallet.Status = "closed"
+	wallet.UpdatedAt = now.Format(time.RFC3339)
 
-	return ctx.GetStub().PutState(walletID, walletJSON)
+	return putWalletState(ctx, wallet)
 }
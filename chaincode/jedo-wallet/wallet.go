@@ -11,28 +11,36 @@ import (
 
 // Wallet represents a wallet asset on the blockchain
 type Wallet struct {
-	DocType   string            `json:"docType"`  // docType is used to distinguish the various types of objects in state database
-	WalletID  string            `json:"walletId"` // Unique wallet identifier
-	OwnerID   string            `json:"ownerId"`  // Owner identifier (e.g., hans.worb.alps.ea.jedo.cc)
-	Balance   float64           `json:"balance"`  // Current balance
-	Currency  string            `json:"currency"` // Currency type (default: JEDO)
-	Status    string            `json:"status"`   // active, frozen, closed
-	CreatedAt string            `json:"createdAt"` // ISO 8601 timestamp
-	UpdatedAt string            `json:"updatedAt"` // ISO 8601 timestamp
-	Metadata  map[string]string `json:"metadata"`  // Additional metadata
+	DocType       string            `json:"docType"`               // docType is used to distinguish the various types of objects in state database
+	WalletID      string            `json:"walletId"`              // Unique wallet identifier
+	OwnerID       string            `json:"ownerId"`               // Owner identifier (e.g., hans.worb.alps.ea.jedo.cc)
+	OwnerType     string            `json:"ownerType"`             // human, gens, or ager/regnum/orbis for treasuries (empty is treated as human)
+	GensID        string            `json:"gensId,omitempty"`      // Gens of a human or gens owner (e.g., worb), indexed for GetWalletsByGens
+	Balance       int64             `json:"balance"`               // Current balance in minor units (see amountScale)
+	Currency      string            `json:"currency"`              // Currency type (default: JEDO)
+	Status        string            `json:"status"`                // active, frozen, closed, blocked (unpaid taxes)
+	CreatedAt     string            `json:"createdAt"`             // ISO 8601 timestamp
+	UpdatedAt     string            `json:"updatedAt"`             // ISO 8601 timestamp
+	Metadata      map[string]string `json:"metadata"`              // Additional metadata
+	SchemaVersion int               `json:"schemaVersion"`         // Document schema version (see currentSchemaVersion)
+	PrivateHash   string            `json:"privateHash,omitempty"` // SHA-256 of the metadata in the Ager's private collection
+	UpdatedBy     string            `json:"updatedBy,omitempty"`   // CN of the identity that wrote this version
 }
 
 // Transaction represents a transaction record
 type Transaction struct {
-	DocType      string  `json:"docType"`
-	TxID         string  `json:"txId"`
-	WalletID     string  `json:"walletId"`
-	Type         string  `json:"type"` // credit, debit, transfer_in, transfer_out
-	Amount       float64 `json:"amount"`
-	Balance      float64 `json:"balance"` // Balance after transaction
-	Counterparty string  `json:"counterparty"` // Other wallet involved (for transfers)
-	Description  string  `json:"description"`
-	Timestamp    string  `json:"timestamp"`
+	DocType       string `json:"docType"`
+	TxID          string `json:"txId"`
+	WalletID      string `json:"walletId"`
+	Type          string `json:"type"`         // credit, debit, transfer_in, transfer_out
+	Amount        int64  `json:"amount"`       // Signed amount in minor units
+	Balance       int64  `json:"balance"`      // Balance after transaction in minor units
+	Counterparty  string `json:"counterparty"` // Other wallet involved (for transfers)
+	Description   string `json:"description"`
+	Timestamp     string `json:"timestamp"`
+	SchemaVersion int    `json:"schemaVersion"`
+	PrivateHash   string `json:"privateHash,omitempty"` // SHA-256 of balance and description in the Ager's private collection
+	Index         int    `json:"index,omitempty"`       // Position within a TransferBatch (1-based), 0 otherwise
 }
 
 // HistoryQueryResult structure used for returning result of history query
@@ -58,7 +66,7 @@ func (s *SmartContract) CreateWallet(
     ctx contractapi.TransactionContextInterface,
     walletID string,
     ownerID string,
-    initialBalance float64,
+    initialBalance string,
     metadataJSON string,
 ) error {
     // Check caller is Gens
@@ -71,29 +79,13 @@ func (s *SmartContract) CreateWallet(
     }
 
     // Verify gens can only create wallets for their own humans
-    callerID, err := ctx.GetClientIdentity().GetID()
+    caller, err := getCallerIdentity(ctx)
     if err != nil {
         return err
     }
 
-    // Extract gens name from caller (e.g., "CN=worb.alps.ea.jedo.cc" -> "worb")
-    gensName := ""
-    parts := strings.Split(callerID, ",")
-    for _, part := range parts {
-        if strings.HasPrefix(strings.TrimSpace(part), "CN=") {
-            cn := strings.TrimPrefix(strings.TrimSpace(part), "CN=")
-            cnParts := strings.Split(cn, ".")
-            if len(cnParts) > 0 {
-                gensName = cnParts[0]
-            }
-            break
-        }
-    }
-
-    // Check if ownerID belongs to this gens (must contain gensName)
-    if gensName != "" &&
-        !strings.Contains(ownerID, "."+gensName+".") &&
-        !strings.HasPrefix(ownerID, gensName+".") {
+    // ownerID must sit exactly one label below the gens (e.g. hans.worb.alps.ea.jedo.cc below worb.alps.ea.jedo.cc)
+    if !caller.isGensOf(ownerID) {
         return fmt.Errorf("you can only create wallets for your own humans")
     }
 
@@ -115,8 +107,15 @@ func (s *SmartContract) CreateWallet(
     }
 
     // Validate inputs
-    if initialBalance < 0 {
-        return fmt.Errorf("initial balance cannot be negative")
+    balance, err := parseAmount(initialBalance)
+    if err != nil {
+        return fmt.Errorf("invalid initial balance: %v", err)
+    }
+
+    // Metadata may come as transient data so it never appears in the proposal
+    metadataJSON, err = getSensitiveArg(ctx, "metadata", metadataJSON)
+    if err != nil {
+        return err
     }
 
     // Parse metadata (ensure non-nil map)
@@ -130,66 +129,71 @@ func (s *SmartContract) CreateWallet(
         }
     }
 
-    // Create wallet
-    now := time.Now().UTC().Format(time.RFC3339)
+    // Create wallet at the transaction time, which is the same on every endorsing peer
+    txTime, err := getTxTime(ctx)
+    if err != nil {
+        return err
+    }
+    now := txTime.Format(time.RFC3339)
     wallet := Wallet{
-        DocType:   "wallet",
-        WalletID:  walletID,
-        OwnerID:   ownerID,
-        Balance:   initialBalance,
-        Currency:  "JEDO",
-        Status:    "active",
-        CreatedAt: now,
-        UpdatedAt: now,
-        Metadata:  metadata, // niemals nil
+        DocType:       "wallet",
+        WalletID:      walletID,
+        OwnerID:       ownerID,
+        OwnerType:     "human",
+        Balance:       balance,
+        Currency:      "JEDO",
+        Status:        "active",
+        CreatedAt:     now,
+        UpdatedAt:     now,
+        Metadata:      metadata, // niemals nil
+        SchemaVersion: currentSchemaVersion,
+    }
+    if path, err := parseOwnerPath(ownerID, levelHuman); err == nil {
+        wallet.GensID = path.Gens
     }
 
-    walletJSON, err := json.Marshal(wallet)
-    if err != nil {
-        return err
+    // Enforce holding cap across all wallets of this owner
+    if balance > 0 {
+        if err := s.requireBootstrapMode(ctx, "an initial balance"); err != nil {
+            return err
+        }
+        if err := s.checkHoldingCap(ctx, &Wallet{WalletID: walletID, OwnerID: ownerID, OwnerType: "human"}, balance); err != nil {
+            return err
+        }
     }
 
-    // Save wallet to state
-    if err := ctx.GetStub().PutState(walletID, walletJSON); err != nil {
-        return fmt.Errorf("failed to put wallet to world state: %v", err)
+    // Save wallet to state (fails for a suspended Ager)
+    if err := createWalletState(ctx, &wallet); err != nil {
+        return err
     }
 
     // Record initial transaction if balance > 0
-    if initialBalance > 0 {
+    if balance > 0 {
         tx := Transaction{
-            DocType:     "transaction",
             TxID:        ctx.GetStub().GetTxID(),
             WalletID:    walletID,
             Type:        "credit",
-            Amount:      initialBalance,
-            Balance:     initialBalance,
+            Amount:      balance,
+            Balance:     balance,
             Description: "Initial balance",
             Timestamp:   now,
         }
 
-        txKey, err := ctx.GetStub().CreateCompositeKey(
-            "transaction",
-            []string{walletID, ctx.GetStub().GetTxID()},
-        )
-        if err != nil {
-            return fmt.Errorf("failed to create composite key: %v", err)
+        if err := putTransaction(ctx, &wallet, &tx); err != nil {
+            return fmt.Errorf("failed to save transaction: %v", err)
         }
 
-        txJSON, err := json.Marshal(tx)
-        if err != nil {
+        // An initial balance is newly minted JEDO
+        if err := recordSupplyChange(ctx, &wallet, balance, 0); err != nil {
             return err
         }
-
-        if err := ctx.GetStub().PutState(txKey, txJSON); err != nil {
-            return fmt.Errorf("failed to save transaction: %v", err)
-        }
     }
 
     // Emit event
     eventPayload := map[string]interface{}{
         "walletId":       walletID,
         "ownerId":        ownerID,
-        "initialBalance": initialBalance,
+        "initialBalance": formatAmount(balance),
         "timestamp":      now,
     }
     eventJSON, _ := json.Marshal(eventPayload)
@@ -217,6 +221,11 @@ func (s *SmartContract) GetWallet(
         return nil, fmt.Errorf("wallet %s does not exist", walletID)
     }
 
+    // Refuse documents that still carry float64 amounts
+    if err := checkSchemaVersion(walletJSON); err != nil {
+        return nil, fmt.Errorf("wallet %s: %v", walletID, err)
+    }
+
     // Unmarshal wallet
     var wallet Wallet
     if err := json.Unmarshal(walletJSON, &wallet); err != nil {
@@ -231,36 +240,98 @@ func (s *SmartContract) GetWallet(
     return &wallet, nil
 }
 
-// GetBalance retrieves the balance of a wallet (only human owner can check their own wallet)
-func (s *SmartContract) GetBalance(ctx contractapi.TransactionContextInterface, walletID string) (float64, error) {
-	// Check caller is Human
+// putWalletState writes a wallet document to the world state and records the caller as its last writer.
+// It fails while MigrateSupplyCounters is counting the balances over several transactions.
+func putWalletState(ctx contractapi.TransactionContextInterface, wallet *Wallet) error {
+	if err := checkNoSupplyMigration(ctx); err != nil {
+		return err
+	}
+
+	if wallet.Metadata == nil {
+		wallet.Metadata = make(map[string]string)
+	}
+
+	updatedBy, err := getCallerCN(ctx)
+	if err != nil {
+		return err
+	}
+	wallet.UpdatedBy = updatedBy
+
+	walletJSON, err := json.Marshal(wallet)
+	if err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().PutState(wallet.WalletID, walletJSON); err != nil {
+		return fmt.Errorf("failed to update wallet %s: %v", wallet.WalletID, err)
+	}
+	return nil
+}
+
+// createWalletState saves a new human or gens wallet with everything that belongs to it: the private metadata,
+// the owner's wallet list, the Ager's endorsement policy and the membership record of a human owner.
+// A suspended Ager cannot onboard new wallets.
+func createWalletState(ctx contractapi.TransactionContextInterface, wallet *Wallet) error {
+	if agerID := walletAger(wallet); agerID != "" {
+		if err := checkAgerNotSuspended(ctx, agerID); err != nil {
+			return err
+		}
+	}
+
+	// In private data mode the metadata goes to the Ager's collection
+	if err := putWalletPrivateDetails(ctx, wallet); err != nil {
+		return err
+	}
+
+	if err := putWalletState(ctx, wallet); err != nil {
+		return err
+	}
+	if _, err := addOwnerWallets(ctx, wallet); err != nil {
+		return err
+	}
+
+	// Only peers of the owning Ager may endorse changes of this wallet
+	if err := applyWalletEndorsementPolicy(ctx, wallet); err != nil {
+		return err
+	}
+
+	// Record the Ager join date for voting eligibility, gens owners have none
+	if err := recordFirstMembership(ctx, wallet.OwnerID); err != nil {
+		return fmt.Errorf("failed to record membership: %v", err)
+	}
+	return nil
+}
+
+// GetBalance retrieves the balance of a wallet (only the owner, human or gens, can check their own wallet)
+func (s *SmartContract) GetBalance(ctx contractapi.TransactionContextInterface, walletID string) (string, error) {
+	// Check caller is Human or Gens
 	callerRole, err := getCallerRole(ctx)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
-	if callerRole != "human" {
-		return 0, fmt.Errorf("only humans can check balance")
+	if callerRole != "human" && callerRole != "gens" {
+		return "", fmt.Errorf("only humans and gens can check balance")
 	}
 
-	// Get caller ID
-	callerID, err := ctx.GetClientIdentity().GetID()
+	// Get caller identity
+	caller, err := getCallerIdentity(ctx)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
 	// Get wallet
 	wallet, err := s.GetWallet(ctx, walletID)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
 
 	// Verify caller owns wallet
-	if !strings.Contains(callerID, wallet.OwnerID) {
-		return 0, fmt.Errorf("you can only check your own balance")
+	if !caller.isOwner(wallet.OwnerID, wallet.OwnerType) {
+		return "", fmt.Errorf("you can only check your own balance")
 	}
 
-	return wallet.Balance, nil
+	return formatAmount(wallet.Balance), nil
 }
 
 // UpdateWallet updates wallet metadata (only owner can update)
@@ -271,7 +342,7 @@ func (s *SmartContract) UpdateWallet(ctx contractapi.TransactionContextInterface
 		return err
 	}
 
-	callerID, err := ctx.GetClientIdentity().GetID()
+	caller, err := getCallerIdentity(ctx)
 	if err != nil {
 		return err
 	}
@@ -282,10 +353,16 @@ func (s *SmartContract) UpdateWallet(ctx contractapi.TransactionContextInterface
 	}
 
 	// Only wallet owner or admin can update
-	if callerRole != "admin" && !strings.Contains(callerID, wallet.OwnerID) {
+	if callerRole != "admin" && !caller.isOwner(wallet.OwnerID, wallet.OwnerType) {
 		return fmt.Errorf("you can only update your own wallet")
 	}
 
+	// Metadata may come as transient data so it never appears in the proposal
+	metadataJSON, err = getSensitiveArg(ctx, "metadata", metadataJSON)
+	if err != nil {
+		return err
+	}
+
 	// Parse new metadata
 	var newMetadata map[string]string
 	err = json.Unmarshal([]byte(metadataJSON), &newMetadata)
@@ -295,14 +372,18 @@ func (s *SmartContract) UpdateWallet(ctx contractapi.TransactionContextInterface
 
 	// Update metadata
 	wallet.Metadata = newMetadata
-	wallet.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-
-	walletJSON, err := json.Marshal(wallet)
+	txTime, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
+	wallet.UpdatedAt = txTime.Format(time.RFC3339)
+
+	// In private data mode the metadata goes to the Ager's collection
+	if err := putWalletPrivateDetails(ctx, wallet); err != nil {
+		return err
+	}
 
-	return ctx.GetStub().PutState(walletID, walletJSON)
+	return putWalletState(ctx, wallet)
 }
 
 // FreezeWallet freezes a wallet (admin only)
@@ -318,17 +399,16 @@ func (s *SmartContract) FreezeWallet(ctx contractapi.TransactionContextInterface
 	}
 
 	wallet.Status = "frozen"
-	wallet.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
-
-	walletJSON, err := json.Marshal(wallet)
+	txTime, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
+	wallet.UpdatedAt = txTime.Format(time.RFC3339)
 
-	return ctx.GetStub().PutState(walletID, walletJSON)
+	return putWalletState(ctx, wallet)
 }
 
-// UnfreezeWallet unfreezes a wallet (admin only)
+// UnfreezeWallet unfreezes a wallet (admin only); while the owner has tax arrears it goes back to blocked
 func (s *SmartContract) UnfreezeWallet(ctx contractapi.TransactionContextInterface, walletID string) error {
 	// Admin check
 	if !isAdmin(ctx) {
@@ -340,13 +420,20 @@ func (s *SmartContract) UnfreezeWallet(ctx contractapi.TransactionContextInterfa
 		return err
 	}
 
-	wallet.Status = "active"
-	wallet.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+	arrears, err := getTaxArrears(ctx, wallet.OwnerID)
+	if err != nil {
+		return err
+	}
 
-	walletJSON, err := json.Marshal(wallet)
+	wallet.Status = "active"
+	if arrears != nil {
+		wallet.Status = "blocked"
+	}
+	txTime, err := getTxTime(ctx)
 	if err != nil {
 		return err
 	}
+	wallet.UpdatedAt = txTime.Format(time.RFC3339)
 
-	return ctx.GetStub().PutState(walletID, walletJSON)
+	return putWalletState(ctx, wallet)
 }
@@ -88,7 +88,10 @@ for REGNUM in $REGNUMS; do
         CC_SEQUENCE="1"
         CC_INIT_FCN="InitLedger"
         CC_END_POLICY=""
-        CC_COLL_CONFIG=""
+        # Private data collections of the Agers on this channel, passed to approve, checkcommitreadiness and commit
+        # (the peers mount $LOCAL_INFRA_DIR/$ORBIS/$REGNUM/configuration as /var/hyperledger/configuration)
+        writeCollectionsConfig $REGNUM "$LOCAL_INFRA_DIR/$ORBIS/$REGNUM/configuration/collections_config.json"
+        CC_COLL_CONFIG="--collections-config /var/hyperledger/configuration/collections_config.json"
         DELAY="3"
         MAX_RETRY="5"
         VERBOSE="false"
@@ -132,7 +135,7 @@ for REGNUM in $REGNUMS; do
                     CCAAS_IP=$(yq eval ".Ager[] | select(.Name == \"$AGER\") | .Peers[] | select(.Name == \"$PEER\") | .CCAAS[] | select(.Name == \"$CCAAS\") | .IP" $CONFIG_FILE)
                     CCAAS_PORT=$(yq eval ".Ager[] | select(.Name == \"$AGER\") | .Peers[] | select(.Name == \"$PEER\") | .CCAAS[] | select(.Name == \"$CCAAS\") | .Port" $CONFIG_FILE)
 
-                    PACKAGE_ID=$(packageChaincode $PEER $CCAAS_NAME $CC_VERSION $CCAAS_SERVER $CCAAS_PORT)
+                    PACKAGE_ID=$(packageChaincode $PEER $CCAAS_NAME $CC_VERSION $CCAAS_SERVER $CCAAS_PORT "$LOCAL_CC_DIR/$CC_NAME/META-INF")
                     if [[ $DEBUG == true ]]; then
                     log_debug "PackageID:" "$PACKAGE_ID"
                     fi
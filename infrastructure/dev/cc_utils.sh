@@ -9,6 +9,39 @@ verifyResult() {
 }
 
 
+###############################################################
+# Function writeCollectionsConfig for a REGNUM channel
+# One private data collection private_<ager> per Ager of the channel,
+# readable only by the peers of that Ager (see chaincode privacy.go)
+###############################################################
+writeCollectionsConfig() {
+    REGNUM=$1
+    COLL_FILE=$2
+    CHANNEL_AGERS=$(yq eval ".Ager[] | select(.Administration.Parent == \"$REGNUM\") | .Name" $CONFIG_FILE)
+
+    mkdir -p "$(dirname "$COLL_FILE")"
+    SEPARATOR=""
+    echo "[" > "$COLL_FILE"
+    for CHANNEL_AGER in $CHANNEL_AGERS; do
+cat >> "$COLL_FILE" <<COLL_EOF
+${SEPARATOR}  {
+    "name": "private_${CHANNEL_AGER}",
+    "policy": "OR('${CHANNEL_AGER}.member')",
+    "requiredPeerCount": 0,
+    "maxPeerCount": 3,
+    "blockToLive": 0,
+    "memberOnlyRead": true,
+    "memberOnlyWrite": false
+  }
+COLL_EOF
+        SEPARATOR=","
+    done
+    echo "]" >> "$COLL_FILE"
+
+    log_debug "Collections config for ${REGNUM}:" "$COLL_FILE" >&2
+}
+
+
 ###############################################################
 # Function packageChaincode for a PEER
 ###############################################################
@@ -18,6 +51,7 @@ packageChaincode() {
     CC_VERSION=$3
     CCAAS_SERVER_NAME=$4
     CCAAS_SERVER_PORT=$5
+    CC_META_DIR=$6
     address="${CCAAS_SERVER_NAME}:${CCAAS_SERVER_PORT}"
     prefix=$(basename "$0")
     tempdir=$(mktemp -d -t "$prefix.XXXXXXXX") || error_exit "Error creating temporary directory"
@@ -32,6 +66,11 @@ cat > "$tempdir/src/connection.json" <<CONN_EOF
 }
 CONN_EOF
 
+    # ship CouchDB indexes (META-INF/statedb/couchdb/indexes) with the package
+    if [ -n "$CC_META_DIR" ] && [ -d "$CC_META_DIR" ]; then
+        cp -r "$CC_META_DIR" "$tempdir/src/META-INF"
+    fi
+
     mkdir -p "$tempdir/pkg"
 
 cat << METADATA-EOF > "$tempdir/pkg/metadata.json"
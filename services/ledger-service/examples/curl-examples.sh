@@ -104,7 +104,7 @@ curl -s -X POST "${GATEWAY_URL}/api/v1/proxy/submit" \
     "channelName": "ea",
     "chaincodeName": "jedo-wallet",
     "functionName": "RegisterGens",
-    "args": ["worb", "WORB Business"]
+    "args": ["worb", "WORB Business", "worb.alps.ea.jedo.cc", "wallet-worb-gens"]
   }' | jq .
 echo ""
 
@@ -41,7 +41,7 @@ curl -X POST http://$GATEWAY_ADR/api/v1/proxy/submit \
     "channelName": "ea",
     "chaincodeName": "jedo-wallet",
     "functionName": "RegisterGens",
-    "args": ["'$TEST_ID'", "'$TEST_NAME'"]
+    "args": ["'$TEST_ID'", "'$TEST_NAME'", "'$TEST_ID'.alps.ea.jedo.cc", "gens-'$TEST_ID'"]
   }' | jq '.'
 echo ""
 